@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+)
+
+// SetNX записує val, лише якщо ключа ще немає. Повертає true, якщо запис відбувся.
+// Як і для Get, надгробок (див. WithNegativeTTL) і запис із застарілими тегами чи залежностями
+// вважаються відсутніми: SetNX замінює їх.
+//
+// Якщо драйвер реалізує AtomicDriver, перевірка і запис атомарні на рівні сховища.
+// Інакше атомарність гарантується лише в межах процесу (смугастий lock Cache).
+func (ch *Cache) SetNX(key, val []byte, expiriesSecond int) (bool, error) {
+	return ch.SetNXCtx(context.Background(), key, val, expiriesSecond)
+}
+
+func (ch *Cache) SetNXCtx(ctx context.Context, key, val []byte, expiriesSecond int) (bool, error) {
+	raw, err := encodeEntry(envelope{Value: val})
+	if err != nil {
+		return false, err
+	}
+	key = userKey(key)
+	if ad, ok := ch.dr.(AtomicDriver); ok {
+		return ch.setNXAtomic(ctx, ad, ch.storageKey(key), raw, expiriesSecond)
+	}
+
+	mu := ch.locks.Get(ch.storageKey(key))
+	mu.Lock()
+	defer mu.Unlock()
+
+	env, exist, err := ch.getEntry(ctx, key)
+	if err != nil || exist && !env.Tombstone {
+		return false, err
+	}
+	return true, DriverSet(ctx, ch.dr, ch.storageKey(key), raw, expiriesSecond)
+}
+
+// setNXAtomic — SetNX через AtomicDriver. Якщо ключ зайнятий записом, який читання вважають
+// відсутнім, саме цей запис замінюється через CompareAndSwap; зміна ключа між кроками дає повтор.
+func (ch *Cache) setNXAtomic(ctx context.Context, ad AtomicDriver, key, raw []byte, expiriesSecond int) (bool, error) {
+	for {
+		set, err := ad.SetIfNotExists(ctx, key, raw, expiriesSecond)
+		if err != nil || set {
+			return set, err
+		}
+		cur, exist, err := DriverGet(ctx, ch.dr, key)
+		if err != nil {
+			return false, err
+		}
+		if !exist {
+			continue
+		}
+		env, exist, err := ch.unwrapEntry(ctx, cur)
+		if err != nil || exist && !env.Tombstone {
+			return false, err
+		}
+		swapped, err := ad.CompareAndSwap(ctx, key, cur, raw, expiriesSecond)
+		if err != nil || swapped {
+			return swapped, err
+		}
+	}
+}
+
+// CAS замінює значення ключа на newVal, лише якщо поточне значення дорівнює old.
+// Повертає true, якщо заміна відбулась; для відсутнього ключа (у тому числі надгробка) повертає false.
+//
+// Порівняння побайтове, тож CAS призначений для значень, записаних через Set/SetNX/CAS;
+// записи з метаданими (наприклад, з OnSet у режимі WithStaleWhileRevalidate) з old не збігаються.
+// Гарантії атомарності — як у SetNX.
+func (ch *Cache) CAS(key, old, newVal []byte, expiriesSecond int) (bool, error) {
+	return ch.CASCtx(context.Background(), key, old, newVal, expiriesSecond)
+}
+
+func (ch *Cache) CASCtx(ctx context.Context, key, old, newVal []byte, expiriesSecond int) (bool, error) {
+	return ch.cas(ctx, userKey(key), old, newVal, expiriesSecond)
+}
+
+// cas — CAS для ключа сховища (див. userKey).
+func (ch *Cache) cas(ctx context.Context, key, old, newVal []byte, expiriesSecond int) (bool, error) {
+	oldRaw, err := encodeEntry(envelope{Value: old})
+	if err != nil {
+		return false, err
+	}
+	newRaw, err := encodeEntry(envelope{Value: newVal})
+	if err != nil {
+		return false, err
+	}
+	if ad, ok := ch.dr.(AtomicDriver); ok {
+		return ad.CompareAndSwap(ctx, ch.storageKey(key), oldRaw, newRaw, expiriesSecond)
+	}
+
+	mu := ch.locks.Get(ch.storageKey(key))
+	mu.Lock()
+	defer mu.Unlock()
+
+	cur, exist, err := DriverGet(ctx, ch.dr, ch.storageKey(key))
+	if err != nil || !exist || !bytes.Equal(cur, oldRaw) {
+		return false, err
+	}
+	return true, DriverSet(ctx, ch.dr, ch.storageKey(key), newRaw, expiriesSecond)
+}
+
+// setNX — SetNX для службового ключа сховища (чанки, теги). Службові ключі не бувають надгробками
+// чи записами із залежностями, тож зайнятий ключ не перечитується.
+func (ch *Cache) setNX(ctx context.Context, key, val []byte, expiriesSecond int) (bool, error) {
+	raw, err := encodeEntry(envelope{Value: val})
+	if err != nil {
+		return false, err
+	}
+	if ad, ok := ch.dr.(AtomicDriver); ok {
+		return ad.SetIfNotExists(ctx, ch.storageKey(key), raw, expiriesSecond)
+	}
+
+	mu := ch.locks.Get(ch.storageKey(key))
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, exist, err := DriverGet(ctx, ch.dr, ch.storageKey(key))
+	if err != nil || exist {
+		return false, err
+	}
+	return true, DriverSet(ctx, ch.dr, ch.storageKey(key), raw, expiriesSecond)
+}
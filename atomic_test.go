@@ -0,0 +1,90 @@
+package cache_test
+
+import (
+	"bytes"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/drivers"
+)
+
+func TestAtomicFreeCache(t *testing.T) {
+	testAtomic(t, newFreeCache())
+}
+
+func TestAtomicBadgerDB(t *testing.T) {
+	testAtomic(t, newBadgerCache(t))
+}
+
+func TestAtomicWriteBehind(t *testing.T) {
+	c := cache.NewCache(drivers.NewWriteBehindDriver(newFreeCacheDriver()))
+	defer c.Close()
+	testAtomic(t, c)
+}
+
+func TestAtomicFallback(t *testing.T) {
+	testAtomic(t, cache.NewCache(plainDriver{newFreeCacheDriver()}))
+}
+
+func testAtomic(t *testing.T, c *cache.Cache) {
+	key := []byte("atomic key")
+
+	// SetNX: рівно один з конкурентних записів перемагає
+	var won atomic.Int32
+	runConcurrently(20, func(i int) {
+		ok, err := c.SetNX(key, []byte(strconv.Itoa(i)), 60)
+		if err != nil {
+			t.Errorf("SetNX(): %v", err)
+		}
+		if ok {
+			won.Add(1)
+		}
+	})
+	if won.Load() != 1 {
+		t.Fatalf("SetNX(): expected exactly one winner, got %d", won.Load())
+	}
+
+	// CAS-інкремент: жодне оновлення не губиться
+	if err := c.Set(key, []byte("0"), 60); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	const workers, perWork = 10, 20
+	runConcurrently(workers, func(int) {
+		for i := 0; i < perWork; {
+			cur, _, err := c.Get(key)
+			if err != nil {
+				t.Errorf("Get(): %v", err)
+				return
+			}
+			n, _ := strconv.Atoi(string(cur))
+			ok, err := c.CAS(key, cur, []byte(strconv.Itoa(n+1)), 60)
+			if err != nil {
+				t.Errorf("CAS(): %v", err)
+				return
+			}
+			if ok {
+				i++
+			}
+		}
+	})
+	val, _, err := c.Get(key)
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if want := strconv.Itoa(workers * perWork); string(val) != want {
+		t.Fatalf("CAS(): expected %s, got %s (lost updates)", want, val)
+	}
+
+	// CAS з невірним old і для відсутнього ключа не пише
+	if ok, err := c.CAS(key, []byte("wrong"), []byte("x"), 60); err != nil || ok {
+		t.Fatalf("CAS() wrong old: ok=%v err=%v", ok, err)
+	}
+	if ok, err := c.CAS([]byte("atomic missing"), nil, []byte("x"), 60); err != nil || ok {
+		t.Fatalf("CAS() missing key: ok=%v err=%v", ok, err)
+	}
+	if val, _, _ := c.Get(key); !bytes.Equal(val, []byte(strconv.Itoa(workers*perWork))) {
+		t.Fatalf("CAS(): value changed by failed swap: %s", val)
+	}
+}
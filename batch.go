@@ -0,0 +1,89 @@
+package cache
+
+import "context"
+
+// GetMany читає кілька ключів за один виклик драйвера (якщо він реалізує BatchDriver).
+// Повертає лише знайдені ключі.
+func (ch *Cache) GetMany(keys [][]byte) (map[string][]byte, error) {
+	return ch.GetManyCtx(context.Background(), keys)
+}
+
+func (ch *Cache) GetManyCtx(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	raws, err := ch.getMany(ctx, userKeys(keys))
+	if err != nil {
+		return nil, err
+	}
+	vals := make(map[string][]byte, len(raws))
+	for key, val := range raws {
+		vals[string(fromUserKey([]byte(key)))] = val
+	}
+	return vals, nil
+}
+
+// getMany читає ключі сховища (див. userKey); результат проіндексований ними ж.
+func (ch *Cache) getMany(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	raws, err := DriverGetMany(ctx, ch.dr, ch.storageKeys(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make(map[string][]byte, len(raws))
+	for key, raw := range raws {
+		env, err := decodeEntry(raw)
+		if err != nil {
+			return nil, err
+		}
+		if env.Tombstone {
+			continue
+		}
+		if env.hasDeps() {
+			if valid, err := ch.entryValid(ctx, &env); err != nil {
+				return nil, err
+			} else if !valid {
+				continue
+			}
+		}
+		vals[key[len(ch.prefix):]] = env.Value
+	}
+	return vals, nil
+}
+
+// SetMany записує кілька ключів з однаковим TTL.
+func (ch *Cache) SetMany(items map[string][]byte, expiriesSecond int) error {
+	return ch.SetManyCtx(context.Background(), items, expiriesSecond)
+}
+
+func (ch *Cache) SetManyCtx(ctx context.Context, items map[string][]byte, expiriesSecond int) error {
+	raws := make(map[string][]byte, len(items))
+	for key, val := range items {
+		raw, err := encodeEntry(envelope{Value: val})
+		if err != nil {
+			return err
+		}
+		raws[string(ch.storageKey(userKey([]byte(key))))] = raw
+	}
+	return DriverSetMany(ctx, ch.dr, raws, expiriesSecond)
+}
+
+// DelMany видаляє кілька ключів.
+func (ch *Cache) DelMany(keys [][]byte) error {
+	return ch.DelManyCtx(context.Background(), keys)
+}
+
+func (ch *Cache) DelManyCtx(ctx context.Context, keys [][]byte) error {
+	return ch.delMany(ctx, userKeys(keys))
+}
+
+// delMany видаляє ключі сховища (див. userKey).
+func (ch *Cache) delMany(ctx context.Context, keys [][]byte) error {
+	return DriverDelMany(ctx, ch.dr, ch.storageKeys(keys))
+}
+
+// userKeys застосовує userKey до кожного ключа.
+func userKeys(keys [][]byte) [][]byte {
+	out := make([][]byte, len(keys))
+	for i, key := range keys {
+		out[i] = userKey(key)
+	}
+	return out
+}
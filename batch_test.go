@@ -0,0 +1,61 @@
+package cache_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestBatchFreeCache(t *testing.T) {
+	testBatch(t, newFreeCache())
+}
+
+func TestBatchBadgerDB(t *testing.T) {
+	testBatch(t, newBadgerCache(t))
+}
+
+func testBatch(t *testing.T, c *cache.Cache) {
+	const n = 200
+
+	items := make(map[string][]byte, n)
+	keys := make([][]byte, 0, n+1)
+	for i := 0; i < n; i++ {
+		k := "batch:" + strconv.Itoa(i)
+		items[k] = []byte("value " + strconv.Itoa(i))
+		keys = append(keys, []byte(k))
+	}
+	keys = append(keys, []byte("batch:missing"))
+
+	if err := c.SetMany(items, 60); err != nil {
+		t.Fatalf("SetMany(): %v", err)
+	}
+
+	got, err := c.GetMany(keys)
+	if err != nil {
+		t.Fatalf("GetMany(): %v", err)
+	}
+	if len(got) != n {
+		t.Fatalf("GetMany(): expected %d found keys, got %d", n, len(got))
+	}
+	for k, v := range items {
+		if !bytes.Equal(got[k], v) {
+			t.Fatalf("GetMany(): key %q want=%q got=%q", k, v, got[k])
+		}
+	}
+
+	if err := c.DelMany(keys[:n/2]); err != nil {
+		t.Fatalf("DelMany(): %v", err)
+	}
+	got, err = c.GetMany(keys)
+	if err != nil {
+		t.Fatalf("GetMany() after DelMany: %v", err)
+	}
+	if len(got) != n-n/2 {
+		t.Fatalf("GetMany() after DelMany: expected %d keys, got %d", n-n/2, len(got))
+	}
+	if _, ok := got[string(keys[0])]; ok {
+		t.Fatalf("GetMany() after DelMany: key %q must be deleted", keys[0])
+	}
+}
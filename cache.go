@@ -1,89 +1,295 @@
 package cache
 
 import (
-	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
 
-	"github.com/vmihailenco/msgpack/v5"
+	"github.com/v-grabko1999/cache/internal/stripe"
 )
 
-func NewCache(dr CacheDriver) *Cache {
-	return &Cache{dr: dr}
+func NewCache(dr CacheDriver, opts ...Option) *Cache {
+	ch := &Cache{
+		dr:           dr,
+		cacheOptions: cacheOptions{now: time.Now, rand: randUnit},
+		bg:           new(sync.WaitGroup),
+		locks:        new(stripe.Lock),
+	}
+	for _, opt := range opts {
+		opt(ch)
+	}
+	return ch
+}
+
+// cacheOptions — налаштування Cache, які задають опції (див. Option).
+// Простори імен копіюють їх цілком (див. Namespace).
+type cacheOptions struct {
+	noSingleFlight bool
+
+	// staleSecond > 0 вмикає stale-while-revalidate для OnSet (див. WithStaleWhileRevalidate).
+	staleSecond int
+
+	// negativeSecond > 0 задає TTL надгробків (див. WithNegativeTTL).
+	negativeSecond int
+
+	// xfetchBeta > 0 вмикає імовірнісне дострокове переобчислення в OnSet (див. WithXFetch).
+	xfetchBeta float64
+
+	now  func() time.Time
+	rand func() float64
 }
 
 type Cache struct {
 	dr CacheDriver
+
+	cacheOptions
+
+	// flight обʼєднує конкурентні промахи OnSet для одного ключа.
+	flight flightGroup
+
+	// refreshing — ключі, для яких уже виконується фонове оновлення.
+	refreshing sync.Map
+	// bg — фонові оновлення, на які чекає Close; спільний для кешу і його просторів імен.
+	bg *sync.WaitGroup
+
+	// locks — запасна атомарність read-modify-write для драйверів без нативної підтримки.
+	// Спільний для кешу і його просторів імен; ключ lock-а — ключ сховища (див. storageKey).
+	locks *stripe.Lock
+
+	// loaders — завантажувачі Get, відсортовані від найдовшого префікса (див. RegisterLoader).
+	loaders   []registeredLoader
+	loadersMu sync.RWMutex
+
+	// prefix — префікс ключів простору імен (див. Namespace); порожній у кореневого кешу.
+	prefix []byte
+
+	// namespaces — створені вкладені простори імен: name → *Cache (див. Namespace).
+	namespaces sync.Map
+
+	stats cacheStats
 }
 
 func (ch *Cache) Get(key []byte) (val []byte, exist bool, err error) {
-	return ch.dr.Get(key)
+	return ch.GetCtx(context.Background(), key)
+}
+
+// GetCtx читає значення ключа. Відсутній ключ, для якого зареєстровано завантажувач,
+// завантажується і зберігається (див. RegisterLoader).
+func (ch *Cache) GetCtx(ctx context.Context, key []byte) (val []byte, exist bool, err error) {
+	if l, ok := ch.loaderFor(key); ok {
+		return ch.getLoaded(ctx, key, l)
+	}
+	return ch.get(ctx, userKey(key))
+}
+
+// get читає значення за ключем сховища (див. userKey) без конверта. Надгробок — відсутнє значення.
+func (ch *Cache) get(ctx context.Context, key []byte) (val []byte, exist bool, err error) {
+	env, exist, err := ch.getEntry(ctx, key)
+	if err != nil || !exist {
+		return nil, exist, err
+	}
+	if env.Tombstone {
+		return nil, false, nil
+	}
+	return env.Value, true, nil
+}
+
+// getEntry читає запис з драйвера і розгортає конверт (див. envelope).
+// Запис із застарілими тегами чи залежностями (див. SetWithTags, SetDependent) вважається відсутнім.
+func (ch *Cache) getEntry(ctx context.Context, key []byte) (env envelope, exist bool, err error) {
+	raw, exist, err := DriverGet(ctx, ch.dr, ch.storageKey(key))
+	if err != nil || !exist {
+		return envelope{}, exist, err
+	}
+	return ch.unwrapEntry(ctx, raw)
+}
+
+// unwrapEntry розгортає прочитаний запис; exist=false — залежності запису застаріли (див. getEntry).
+func (ch *Cache) unwrapEntry(ctx context.Context, raw []byte) (env envelope, exist bool, err error) {
+	env, err = decodeEntry(raw)
+	if err != nil {
+		return envelope{}, true, err
+	}
+	if env.hasDeps() {
+		if valid, err := ch.entryValid(ctx, &env); err != nil || !valid {
+			return envelope{}, false, err
+		}
+	}
+	return env, true, nil
+}
+
+// setEntry загортає значення в конверт (лише якщо є метадані) і записує в драйвер.
+func (ch *Cache) setEntry(ctx context.Context, key []byte, env envelope, expiriesSecond int) error {
+	raw, err := encodeEntry(env)
+	if err != nil {
+		return err
+	}
+	return DriverSet(ctx, ch.dr, ch.storageKey(key), raw, expiriesSecond)
 }
 
 func (ch *Cache) GetAndDel(key []byte) (val []byte, exist bool, err error) {
-	val, exist, err = ch.Get(key)
+	return ch.GetAndDelCtx(context.Background(), key)
+}
+
+func (ch *Cache) GetAndDelCtx(ctx context.Context, key []byte) (val []byte, exist bool, err error) {
+	key = userKey(key)
+	val, exist, err = ch.get(ctx, key)
 	if err != nil {
 		return
 	}
 
 	if exist {
-		ch.Del(key)
+		ch.del(ctx, key)
 	}
 	return
 }
 
 func (ch *Cache) Set(key, val []byte, expiriesSecond int) error {
-	return ch.dr.Set(key, val, expiriesSecond)
+	return ch.SetCtx(context.Background(), key, val, expiriesSecond)
+}
+
+func (ch *Cache) SetCtx(ctx context.Context, key, val []byte, expiriesSecond int) error {
+	return ch.set(ctx, userKey(key), val, expiriesSecond)
+}
+
+// set записує значення за ключем сховища (див. userKey).
+func (ch *Cache) set(ctx context.Context, key, val []byte, expiriesSecond int) error {
+	return ch.setEntry(ctx, key, envelope{Value: val}, expiriesSecond)
 }
 
 type OnSet func() (value []byte, err error)
 
+// OnSetCtx — фабрика значення для OnSetCtx, яка отримує context викликача.
+type OnSetCtx func(ctx context.Context) (value []byte, err error)
+
 func (ch *Cache) OnSet(key []byte, fn OnSet, expiriesSecond int) (val []byte, err error) {
-	val, exist, err := ch.Get(key)
+	return ch.OnSetCtx(context.Background(), key, func(context.Context) ([]byte, error) {
+		return fn()
+	}, expiriesSecond)
+}
+
+// OnSetCtx повертає значення з кешу, а якщо його немає — викликає fn і зберігає результат.
+// Конкурентні промахи для одного ключа обʼєднуються в один виклик fn (див. WithoutSingleFlight).
+//
+// Якщо fn повертає ErrNotFoundCacheable, замість значення зберігається надгробок (див. WithNegativeTTL),
+// і до його завершення OnSet повертає ErrNotFound, не викликаючи fn.
+//
+// У режимі WithStaleWhileRevalidate застаріле (але ще не видалене) значення повертається одразу,
+// а fn викликається у фоні і може виконуватися вже після повернення OnSetCtx.
+func (ch *Cache) OnSetCtx(ctx context.Context, key []byte, fn OnSetCtx, expiriesSecond int) (val []byte, err error) {
+	key = userKey(key)
+	env, exist, err := ch.getEntry(ctx, key)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if exist {
+		switch {
+		case env.Tombstone:
+			return nil, ErrNotFound
+		case ch.isStale(&env):
+			ch.revalidate(ctx, key, fn, expiriesSecond)
+		case ch.shouldRecomputeEarly(&env):
+			ch.stats.earlyRecomputes.Add(1)
+			return ch.load(ctx, key, fn, expiriesSecond)
+		}
+		return env.Value, nil
 	}
-	if !exist {
-		val, err = fn()
+	return ch.load(ctx, key, fn, expiriesSecond)
+}
+
+// load викликає fn і зберігає результат, обʼєднуючи конкурентні виклики для одного ключа.
+func (ch *Cache) load(ctx context.Context, key []byte, fn OnSetCtx, expiriesSecond int) (val []byte, err error) {
+	load := func() ([]byte, error) {
+		start := ch.now()
+		val, err := fn(ctx)
+		if errors.Is(err, ErrNotFoundCacheable) {
+			if err := ch.storeTombstone(ctx, key, expiriesSecond); err != nil {
+				return nil, err
+			}
+			return nil, ErrNotFound
+		}
 		if err != nil {
-			return
+			return nil, err
 		}
-		err = ch.Set(key, val, expiriesSecond)
+		return val, ch.storeLoaded(ctx, key, val, expiriesSecond, ch.now().Sub(start))
+	}
+	if ch.noSingleFlight {
+		return load()
+	}
+
+	val, err, shared := ch.flight.do(ctx, string(key), load)
+	if shared {
+		ch.stats.onSetCoalesced.Add(1)
 	}
 	return
 }
 
 func (ch *Cache) Del(key []byte) error {
-	return ch.dr.Del(key)
+	return ch.DelCtx(context.Background(), key)
 }
 
-func (ch *Cache) Clear() error {
-	return ch.dr.Clear()
+func (ch *Cache) DelCtx(ctx context.Context, key []byte) error {
+	return ch.del(ctx, userKey(key))
 }
 
-func (ch *Cache) Chunk(name string, expiriesSecond int) (*Chunk, error) {
-	if _, err := ch.OnSet(getChunkKey(name), func() ([]byte, error) {
-		var buffer bytes.Buffer
-		enc := msgpack.NewEncoder(&buffer)
+// del видаляє ключ сховища (див. userKey).
+func (ch *Cache) del(ctx context.Context, key []byte) error {
+	return DriverDel(ctx, ch.dr, ch.storageKey(key))
+}
 
-		// Порожній чанк (Version=0, Data=empty map) у msgpack.
-		initial := ChunkRaw{
-			Version: 0,
-			Data:    make(map[string][]byte),
-		}
-		if err := enc.Encode(&initial); err != nil {
-			return nil, err
-		}
-		return buffer.Bytes(), nil
-	}, expiriesSecond); err != nil {
-		return nil, err
+func (ch *Cache) Clear() error {
+	return ch.ClearCtx(context.Background())
+}
+
+// ClearCtx видаляє всі ключі кешу. Для простору імен (див. Namespace) видаляються лише його ключі,
+// чанки і вкладені простори: DelPrefix драйвера (Badger — DropPrefix) або обхід з видаленням.
+func (ch *Cache) ClearCtx(ctx context.Context) error {
+	if len(ch.prefix) > 0 {
+		return ch.delPrefix(ctx, nil)
 	}
+	return DriverClear(ctx, ch.dr)
+}
 
+func (ch *Cache) Chunk(name string, expiriesSecond int, opts ...ChunkOption) (*Chunk, error) {
+	return ch.ChunkCtx(context.Background(), name, expiriesSecond, opts...)
+}
+
+func (ch *Cache) ChunkCtx(ctx context.Context, name string, expiriesSecond int, opts ...ChunkOption) (*Chunk, error) {
 	chunk := &Chunk{
 		ch:             ch,
 		name:           name,
 		expiriesSecond: expiriesSecond,
 	}
+	for _, opt := range opts {
+		opt(&chunk.opts)
+	}
+	if chunk.opts.codec == nil {
+		chunk.opts.codec = CodecMsgpack
+	}
+	if chunk.opts.pages > 0 && (chunk.opts.deltaLog || chunk.opts.merge) ||
+		chunk.opts.lazy && (chunk.opts.deltaLog || chunk.opts.pages > 0 || chunk.opts.codec.Name() != CodecMsgpack.Name()) {
+		return nil, ErrChunkOptions
+	}
+
+	// Порожній чанк (Data=empty map) з початковою версією newChunkVersion, закодований кодеком чанку.
+	// SetNX, а не OnSet: payload чанку не має потрапляти під stale-while-revalidate/XFetch.
+	// У форматах WithDeltaLog і WithPages перший payload створює перший коміт.
+	if !chunk.opts.deltaLog && chunk.opts.pages == 0 {
+		initial, err := encodeChunkRaw(ChunkRaw{
+			Version: ch.newChunkVersion(),
+			Data:    make(map[string][]byte),
+		}, chunk.opts.codec)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ch.setNX(ctx, getChunkKey(name), initial, expiriesSecond); err != nil {
+			return nil, err
+		}
+	}
 
-	if err := chunk.loadToMemory(); err != nil {
+	if err := chunk.loadToMemory(ctx); err != nil {
 		return nil, err
 	}
 
@@ -91,9 +297,39 @@ func (ch *Cache) Chunk(name string, expiriesSecond int) (*Chunk, error) {
 }
 
 func (ch *Cache) DeleteChunk(name string) error {
-	return ch.Del(getChunkKey(name))
+	return ch.DeleteChunkCtx(context.Background(), name)
 }
 
+// DeleteChunkCtx видаляє payload, службові ключі, сторінки WithPages і журнал змін WithDeltaLog чанку.
+// Сторінки беруться з маніфесту: без TTL (expiriesSecond == 0) вони інакше лишилися б назавжди.
+// Записи журналу видаляються, бо після versionKey, що зник, журнал застосовується, доки є записи,
+// і старі записи потрапили б у чанк, створений заново.
+func (ch *Cache) DeleteChunkCtx(ctx context.Context, name string) error {
+	deltas, err := ch.deltaLogKeys(ctx, name)
+	if err != nil {
+		return err
+	}
+	pages, err := ch.pageKeys(ctx, name)
+	if err != nil {
+		return err
+	}
+	keys := append(deltas, pages...)
+	return ch.delMany(ctx, append(keys,
+		getChunkKey(name), getChunkVersionKey(name), getChunkBaseKey(name), getChunkPagesKey(name),
+	))
+}
+
+// randUnit — джерело випадкових чисел за замовчуванням, повертає значення з (0, 1].
+func randUnit() float64 {
+	return 1 - rand.Float64()
+}
+
+// Close чекає на завершення фонових оновлень і закриває драйвер.
+// Для простору імен (див. Namespace) нічого не робить: драйвер закриває кореневий кеш.
 func (ch *Cache) Close() error {
+	if len(ch.prefix) > 0 {
+		return nil
+	}
+	ch.bg.Wait()
 	return ch.dr.Close()
 }
@@ -2,7 +2,10 @@ package cache_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"runtime/debug"
+	"sync"
 	"testing"
 	"time"
 
@@ -20,6 +23,54 @@ func TestFreeCacheDriver(t *testing.T) {
 	testLogic(t, cache.NewCache(drivers.NewFreeCacheDriver(ch)))
 }
 
+func TestBadgerDBDriver(t *testing.T) {
+	dr, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatal("badger open", err)
+	}
+	ch := cache.NewCache(dr)
+	defer ch.Close()
+
+	testLogic(t, ch)
+}
+
+func newFreeCacheDriver() cache.CacheDriver {
+	return drivers.NewFreeCacheDriver(freecache.NewCache(10 * 1024 * 1024))
+}
+
+func newFreeCache(opts ...cache.Option) *cache.Cache {
+	return cache.NewCache(newFreeCacheDriver(), opts...)
+}
+
+// runConcurrently запускає n горутин одночасно і чекає на їх завершення.
+func runConcurrently(n int, fn func(i int)) {
+	var (
+		start = make(chan struct{})
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+}
+
+func newBadgerCache(t *testing.T, opts ...cache.Option) *cache.Cache {
+	t.Helper()
+	dr, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	c := cache.NewCache(dr, opts...)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
 var (
 	Key   = []byte("test key")
 	Value = []byte("test value")
@@ -30,6 +81,7 @@ func testLogic(t *testing.T, ch *cache.Cache) {
 	testGetAndDel(t, ch)
 	testOnSet(t, ch)
 	testClear(t, ch)
+	testContext(t, ch)
 }
 
 func testGetSet(t *testing.T, ch *cache.Cache) {
@@ -119,3 +171,33 @@ func testClear(t *testing.T, ch *cache.Cache) {
 	}
 	t.Log("ok")
 }
+
+type ctxKey struct{}
+
+func testContext(t *testing.T, ch *cache.Cache) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := ch.GetCtx(ctx, Key); !errors.Is(err, context.Canceled) {
+		t.Fatal("GetCtx: expected context.Canceled, got", err)
+	}
+	if err := ch.SetCtx(ctx, Key, Value, 2); !errors.Is(err, context.Canceled) {
+		t.Fatal("SetCtx: expected context.Canceled, got", err)
+	}
+
+	// ctx викликача має доходити до фабрики значення
+	ctx = context.WithValue(context.Background(), ctxKey{}, "marker")
+	vv, err := ch.OnSetCtx(ctx, []byte("ctx key"), func(ctx context.Context) ([]byte, error) {
+		if ctx.Value(ctxKey{}) != "marker" {
+			t.Fatal("OnSetCtx: caller context was not passed to fn")
+		}
+		return Value, nil
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Value, vv) {
+		t.Fatal("cache invalid data", Value, vv)
+	}
+	t.Log("ok")
+}
@@ -1,19 +1,25 @@
 package cache
 
 import (
-	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"sync"
-
-	"github.com/vmihailenco/msgpack/v5"
 )
 
 var (
 	// ErrChunkConflict означає, що чанк був змінений іншим writer-ом між loadToMemory() і SaveChanges().
 	// Це “оптимістична транзакція”: треба повторити операцію (перезавантажити чанк і застосувати зміни знову).
 	ErrChunkConflict = errors.New("конфлікт версії чанку: дані були змінені паралельно")
+
+	// ErrChunkCorrupted означає, що службові ключі чанку неузгоджені і не відновлюються повторним читанням
+	// (наприклад, драйвер витіснив частину журналу змін). Чанк треба видалити через DeleteChunk.
+	ErrChunkCorrupted = errors.New("службові дані чанку пошкоджені або частково витіснені")
+
+	// ErrChunkLayout означає, що чанк збережено в іншому форматі (WithDeltaLog, WithPages)
+	// або з іншою кількістю сторінок, ніж задано опціями Cache.Chunk.
+	ErrChunkLayout = errors.New("чанк збережено в іншому форматі зберігання")
 )
 
 // Chunk — це “снапшотний” KV-буфер поверх Cache, який працює у дві фази:
@@ -28,7 +34,8 @@ var (
 // щоб детектити паралельні модифікації іншими writer-ами.
 //
 // Серіалізація:
-// payload ChunkRaw кодується msgpack, а значення в Data зберігаються як []byte (частіше це msgpack-пакети).
+// payload ChunkRaw і значення Set/Get/OnSet кодуються кодеком чанку (за замовчуванням msgpack, див. WithCodec),
+// а значення в Data зберігаються як []byte.
 type Chunk struct {
 	ch             *Cache
 	name           string
@@ -42,6 +49,27 @@ type Chunk struct {
 
 	mu      sync.Mutex
 	changes bool
+
+	// dirty — ключі, змінені з моменту loadToMemory (або останнього коміту),
+	// разом з їхніми базовими значеннями. Використовується для злиття (див. WithMerge).
+	dirty map[string]dirtyEntry
+
+	opts chunkOptions
+
+	// delta — стан базового снапшота для WithDeltaLog.
+	delta deltaState
+
+	// pages — сторінки шардованого чанку (WithPages).
+	pages chunkPages
+
+	// lazy — ще не декодований payload (WithLazyLoad); memoryData.Data тоді містить лише зміни поверх нього.
+	lazy *lazyPayload
+
+	// err — "липка" помилка лінивого завантаження сторінки чи payload (див. Err).
+	err error
+
+	// flight обʼєднує конкурентні OnSet/OnSetRaw для одного ключа цього снапшота.
+	flight flightGroup
 }
 
 // ChunkRaw — серіалізований стан чанку.
@@ -50,40 +78,141 @@ type Chunk struct {
 type ChunkRaw struct {
 	Version uint64
 	Data    map[string][]byte
+	// Codec — ідентифікатор кодека payload (див. Codec); порожній для msgpack.
+	Codec string `msgpack:",omitempty" json:",omitempty"`
 }
 
 // getChunkKey створює ключ для збереження payload чанку в кеші.
 func getChunkKey(name string) []byte {
-	return []byte("cache_package_chank_" + name)
+	return chunkKey(name, chunkPartPayload)
 }
 
 // getChunkVersionKey створює ключ для окремого збереження версії payload (8 байт LE).
 // Використовується для швидкої CAS-перевірки без читання всього payload.
 func getChunkVersionKey(name string) []byte {
-	return []byte("cache_package_chank_" + name + "_version")
+	return chunkKey(name, chunkPartVersion)
+}
+
+// encodeVersion кодує версію для versionKey (8 байт LE).
+func encodeVersion(ver uint64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, ver)
+}
+
+// decodeVersion розбирає значення versionKey. Довжина має бути рівно 8 байт.
+func decodeVersion(b []byte) (uint64, error) {
+	if len(b) != 8 {
+		return 0, fmt.Errorf("invalid chunk version bytes len=%d", len(b))
+	}
+	return binary.LittleEndian.Uint64(b), nil
 }
 
 // loadVersionKey читає versionKey з кешу.
 // Повертає (ver, exist, err). Якщо ключ існує, його довжина має бути рівно 8 байт.
-func (ch *Chunk) loadVersionKey() (uint64, bool, error) {
-	b, exist, err := ch.ch.Get(getChunkVersionKey(ch.name))
+func (ch *Chunk) loadVersionKey(ctx context.Context) (uint64, bool, error) {
+	b, exist, err := ch.ch.get(ctx, getChunkVersionKey(ch.name))
 	if err != nil {
 		return 0, false, err
 	}
 	if !exist {
 		return 0, false, nil
 	}
-	if len(b) != 8 {
-		return 0, true, fmt.Errorf("invalid chunk version bytes len=%d", len(b))
+	ver, err := decodeVersion(b)
+	return ver, true, err
+}
+
+// initVersionKey створює versionKey (8 байт LE) з TTL чанку, якщо його ще немає.
+// Повертає false, якщо ключ паралельно створив інший writer.
+func (ch *Chunk) initVersionKey(ctx context.Context, ver uint64) (bool, error) {
+	return ch.ch.setNX(ctx, getChunkVersionKey(ch.name), encodeVersion(ver), ch.expiriesSecond)
+}
+
+// newChunkVersion повертає початкову версію нового чанку — поточний час у наносекундах.
+// Чанк, створений заново після DeleteChunk чи TTL, не повторює версій попереднього,
+// тож записи SetDependent, що залежать від старого чанку, не оживають (ABA).
+func (ch *Cache) newChunkVersion() uint64 {
+	return uint64(ch.now().UnixNano())
+}
+
+// chunkSnapshot — прочитаний з кешу стан чанку.
+type chunkSnapshot struct {
+	raw         ChunkRaw
+	verKey      uint64
+	verKeyExist bool
+
+	// fresh — у кеші немає ні payload, ні маніфесту: версію чанку задає лише versionKey.
+	fresh bool
+
+	// delta — стан базового снапшота (лише для WithDeltaLog).
+	delta deltaState
+
+	// pages — маніфест сторінок (лише для WithPages); legacyPayload — дані взято
+	// з payload звичайного формату, бо маніфесту ще немає.
+	pages         []pageMeta
+	legacyPayload bool
+
+	// payload — сирий payload, не декодований у raw.Data (лише для WithLazyLoad).
+	payload []byte
+}
+
+// readChunk читає поточний стан чанку з урахуванням формату зберігання.
+//
+// versionKey і payload читаються одним GetMany: для драйверів з BatchDriver (Badger)
+// це один консистентний снапшот, тож атомарний коміт SaveChanges не видно "наполовину".
+func (ch *Chunk) readChunk(ctx context.Context) (chunkSnapshot, error) {
+	switch {
+	case ch.opts.deltaLog:
+		return ch.readDeltaChunk(ctx)
+	case ch.opts.pages > 0:
+		return ch.readPagedChunk(ctx)
+	}
+	return ch.readPlainChunk(ctx, false)
+}
+
+// readPlainChunk читає чанк звичайного формату. Якщо lazy, payload не декодується:
+// зі снапшота читається лише Version, а сирі байти повертаються в snap.payload (див. WithLazyLoad).
+func (ch *Chunk) readPlainChunk(ctx context.Context, lazy bool) (chunkSnapshot, error) {
+	versionKey, payloadKey := getChunkVersionKey(ch.name), getChunkKey(ch.name)
+	baseKey, pagesKey := getChunkBaseKey(ch.name), getChunkPagesKey(ch.name)
+	vals, err := ch.ch.getMany(ctx, [][]byte{versionKey, payloadKey, baseKey, pagesKey})
+	if err != nil {
+		return chunkSnapshot{}, err
+	}
+	if _, ok := vals[string(baseKey)]; ok {
+		return chunkSnapshot{}, ErrChunkLayout
+	}
+	if _, ok := vals[string(pagesKey)]; ok {
+		return chunkSnapshot{}, ErrChunkLayout
+	}
+
+	var snap chunkSnapshot
+	if snap.verKey, snap.verKeyExist, err = decodeVersionKey(vals, versionKey); err != nil {
+		return chunkSnapshot{}, err
 	}
-	return binary.LittleEndian.Uint64(b), true, nil
+
+	payload, payloadExist := vals[string(payloadKey)]
+	if lazy && payloadExist {
+		snap.raw = ChunkRaw{Data: make(map[string][]byte)}
+		if snap.raw.Version, err = decodeLazyVersion(payload); err != nil {
+			return chunkSnapshot{}, err
+		}
+		snap.payload = payload
+		return snap, nil
+	}
+	if snap.raw, err = decodeChunkRaw(payload, payloadExist, ch.opts.codec); err != nil {
+		return chunkSnapshot{}, err
+	}
+	snap.fresh = !payloadExist
+	return snap, nil
 }
 
-// saveVersionKey записує versionKey у кеш (8 байт LE) з TTL чанку.
-func (ch *Chunk) saveVersionKey(ver uint64) error {
-	var buf [8]byte
-	binary.LittleEndian.PutUint64(buf[:], ver)
-	return ch.ch.Set(getChunkVersionKey(ch.name), buf[:], ch.expiriesSecond)
+// decodeVersionKey дістає і декодує versionKey з результату GetMany.
+func decodeVersionKey(vals map[string][]byte, versionKey []byte) (uint64, bool, error) {
+	b, exist := vals[string(versionKey)]
+	if !exist {
+		return 0, false, nil
+	}
+	ver, err := decodeVersion(b)
+	return ver, true, err
 }
 
 // loadToMemory завантажує payload чанку з кешу у RAM та ініціалізує baseVersion.
@@ -92,58 +221,96 @@ func (ch *Chunk) saveVersionKey(ver uint64) error {
 // якщо versionKey існує, його значення має збігатися з ChunkRaw.Version.
 //
 // Ініціалізація:
-// якщо versionKey відсутній, він створюється зі значенням payload.Version.
-func (ch *Chunk) loadToMemory() error {
+// якщо versionKey відсутній, він створюється зі значенням payload.Version (див. readSnapshot).
+func (ch *Chunk) loadToMemory(ctx context.Context) error {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	verKey, verKeyExist, err := ch.loadVersionKey()
-	if err != nil {
-		return err
-	}
-
-	chunkData, err := ch.getOrCreateChunkRaw()
+	snap, err := ch.readSnapshot(ctx)
 	if err != nil {
 		return err
 	}
+	chunkData := snap.raw
 
-	// Якщо версія-ключ існує — вона має збігатись з версією у payload.
-	if verKeyExist && chunkData.Version != verKey {
-		return ErrChunkConflict
-	}
-
-	// Якщо verKey не існує — ініціалізуємо його з payload (або 0).
-	if !verKeyExist {
-		if err := ch.saveVersionKey(chunkData.Version); err != nil {
-			return err
-		}
+	if ch.opts.pages > 0 {
+		ch.resetPages(snap)
+		chunkData.Data = nil
 	}
-
 	ch.memoryData = cloneChunkRaw(chunkData)
 	ch.baseVersion = chunkData.Version
+	ch.delta = snap.delta
+	ch.lazy = nil
+	if snap.payload != nil {
+		ch.lazy = &lazyPayload{raw: snap.payload}
+	}
 	ch.changes = false
+	ch.dirty = nil
 	return nil
 }
 
-// Set кодує val у msgpack та зберігає результат у RAM-снапшоті.
+// snapshotReadAttempts обмежує кількість перечитувань, коли versionKey не збігається з версією payload.
+// Без BatchDriver versionKey і payload читаються окремими Get, і паралельний коміт
+// між ними дає розбіжність, яка зникає при перечитуванні.
+const snapshotReadAttempts = 5
+
+// readSnapshot читає снапшот чанку і перевіряє, що versionKey збігається з версією payload.
+// Розбіжність перечитується до snapshotReadAttempts разів, потім повертається ErrChunkConflict.
+// Відсутній versionKey створюється через SetNX; новий чанк отримує початкову версію newChunkVersion.
+// Якщо його паралельно створив інший writer (наприклад, два конкурентні відкриття нового чанку),
+// снапшот перечитується: відкриття ідемпотентне.
+func (ch *Chunk) readSnapshot(ctx context.Context) (chunkSnapshot, error) {
+	for attempt := 1; ; attempt++ {
+		var (
+			snap chunkSnapshot
+			err  error
+		)
+		if ch.opts.lazy {
+			snap, err = ch.readPlainChunk(ctx, true)
+		} else {
+			snap, err = ch.readChunk(ctx)
+		}
+		if err != nil {
+			return chunkSnapshot{}, err
+		}
+
+		if snap.verKeyExist {
+			if snap.raw.Version == snap.verKey {
+				return snap, nil
+			}
+			if attempt >= snapshotReadAttempts {
+				return chunkSnapshot{}, ErrChunkConflict
+			}
+			continue
+		}
+		if snap.fresh {
+			snap.raw.Version = ch.ch.newChunkVersion()
+		}
+		created, err := ch.initVersionKey(ctx, snap.raw.Version)
+		if err != nil || created {
+			return snap, err
+		}
+	}
+}
+
+// Set кодує val кодеком чанку та зберігає результат у RAM-снапшоті.
 // Значення в RAM зберігається як копія []byte (див. SetRaw).
+// Повертає помилку завантаження сторінки ключа (див. Err), якщо запис не прийнято.
 func (ch *Chunk) Set(key []byte, val any) error {
-	b, err := msgpack.Marshal(val)
+	b, err := ch.opts.codec.Marshal(val)
 	if err != nil {
 		return err
 	}
-	ch.SetRaw(key, b)
-	return nil
+	return ch.setRaw(key, b)
 }
 
-// Get читає []byte з RAM-снапшота та декодує msgpack у dst (dst має бути вказівником).
-// Повертає exist=false, якщо ключ відсутній.
+// Get читає []byte з RAM-снапшота та декодує його кодеком чанку у dst (dst має бути вказівником).
+// Повертає exist=false, якщо ключ відсутній, і помилку, якщо ключ не вдалося прочитати (див. Err).
 func (ch *Chunk) Get(key []byte, dst any) (exist bool, err error) {
-	raw, exist := ch.GetRaw(key)
-	if !exist {
-		return false, nil
+	raw, exist, err := ch.getRaw(key)
+	if err != nil || !exist {
+		return false, err
 	}
-	if err := msgpack.Unmarshal(raw, dst); err != nil {
+	if err := ch.opts.codec.Unmarshal(raw, dst); err != nil {
 		return true, err
 	}
 	return true, nil
@@ -153,12 +320,22 @@ func (ch *Chunk) Get(key []byte, dst any) (exist bool, err error) {
 // Викликається лише якщо ключ відсутній у RAM-снапшоті.
 type OnSetCh func() (value any, err error)
 
+// OnSetChCtx — фабрика значення для OnSetCtx, яка отримує context викликача.
+type OnSetChCtx func(ctx context.Context) (value any, err error)
+
 // OnSet заповнює dst даними з ключа, а якщо ключ відсутній — генерує значення через fn,
-// кодує його у msgpack, зберігає у RAM через SetRaw і декодує у dst.
+// кодує його кодеком чанку, зберігає у RAM через SetRaw і декодує у dst.
 //
-// На відміну від OnSetRaw, цей метод працює з типізованими значеннями (any <-> msgpack).
+// На відміну від OnSetRaw, цей метод працює з типізованими значеннями (any <-> кодек чанку).
 // Метод не викликає SaveChanges(): коміт залишається відповідальністю викликача.
 func (ch *Chunk) OnSet(key []byte, dst any, fn OnSetCh) error {
+	return ch.OnSetCtx(context.Background(), key, dst, func(context.Context) (any, error) {
+		return fn()
+	})
+}
+
+// OnSetCtx — варіант OnSet, який передає ctx у фабрику значення.
+func (ch *Chunk) OnSetCtx(ctx context.Context, key []byte, dst any, fn OnSetChCtx) error {
 	// 1) швидкий шлях: спробувати прочитати з RAM
 	if ok, err := ch.Get(key, dst); err != nil {
 		return err
@@ -166,46 +343,95 @@ func (ch *Chunk) OnSet(key []byte, dst any, fn OnSetCh) error {
 		return nil
 	}
 
-	// 2) якщо немає — генеруємо значення
-	v, err := fn()
+	// 2) якщо немає — генеруємо значення, кодуємо та кладемо в RAM
+	b, err := ch.loadRaw(ctx, key, func(ctx context.Context) ([]byte, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ch.opts.codec.Marshal(v)
+	})
 	if err != nil {
 		return err
 	}
 
-	// 3) кодуємо та кладемо в RAM
-	b, err := msgpack.Marshal(v)
-	if err != nil {
+	// 3) декодуємо назад у dst (щоб dst гарантовано заповнився даними саме з кодека)
+	if err := ch.opts.codec.Unmarshal(b, dst); err != nil {
 		return err
 	}
 
-	ch.SetRaw(key, b)
+	return nil
+}
+
+// data повертає map RAM-снапшота, у якій живе key: для WithPages — сторінку ключа
+// (завантажену на вимогу і, якщо write, позначену зміненою), інакше — memoryData.Data.
+// Повертає nil, якщо сторінку не вдалося завантажити (помилка — у ch.err). Викликається під mu.
+func (ch *Chunk) data(key string, write bool) map[string][]byte {
+	if ch.opts.pages > 0 {
+		return ch.pageData(key, write)
+	}
+	if write && ch.memoryData.Data == nil {
+		ch.memoryData.Data = make(map[string][]byte)
+	}
+	return ch.memoryData.Data
+}
 
-	// 4) декодуємо назад у dst (щоб dst гарантовано заповнився даними саме з кодека)
-	if err := msgpack.Unmarshal(b, dst); err != nil {
-		return err
+// lookup читає значення ключа з RAM-снапшота (без копіювання). Викликається під mu.
+// Якщо сторінку ключа не вдалося завантажити або payload WithLazyLoad — декодувати,
+// повертає цю помилку, а не промах.
+func (ch *Chunk) lookup(key string) ([]byte, bool, error) {
+	data := ch.data(key, false)
+	if data == nil && ch.err != nil {
+		return nil, false, ch.err
+	}
+	v, ok := data[key]
+	if ok || ch.lazy == nil {
+		return v, ok, nil
 	}
+	return ch.lazyGet(key)
+}
 
-	return nil
+// remove видаляє ключ з data (результат ch.data(key, true)). Викликається під mu.
+func (ch *Chunk) remove(data map[string][]byte, key string) {
+	delete(data, key)
+	if ch.lazy != nil {
+		ch.lazy.remove(key)
+	}
+}
+
+// Err повертає помилку лінивого завантаження (WithPages, WithLazyLoad), якщо вона сталася.
+// Після неї Get і OnSet відповідних ключів повертають цю помилку (GetRaw — промах), записи в них не приймаються,
+// а SaveChanges повертає цю ж помилку:
+// ErrChunkConflict — сторінку переписав інший writer після відкриття снапшота,
+// ErrChunkCorrupted — сторінка витіснена драйвером, інше — помилка драйвера чи декодування.
+// Снапшот треба відкрити заново.
+func (ch *Chunk) Err() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.err
 }
 
 // GetRaw повертає значення з RAM-снапшота по ключу.
 // Повертає копію []byte, щоб викликач не міг мутувати внутрішній стан чанку.
+// Якщо ключ не вдалося прочитати (WithPages, WithLazyLoad), повертає exist=false, а помилку видно з Err.
 func (ch *Chunk) GetRaw(key []byte) (val []byte, exist bool) {
+	val, exist, _ = ch.getRaw(key)
+	return val, exist
+}
+
+// getRaw — GetRaw, що повертає помилку читання ключа (див. Err).
+func (ch *Chunk) getRaw(key []byte) ([]byte, bool, error) {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	if ch.memoryData.Data == nil {
-		return nil, false
-	}
-
-	v, ok := ch.memoryData.Data[string(key)]
-	if !ok {
-		return nil, false
+	v, ok, err := ch.lookup(string(key))
+	if err != nil || !ok {
+		return nil, false, err
 	}
 
 	valCopy := make([]byte, len(v))
 	copy(valCopy, v)
-	return valCopy, true
+	return valCopy, true, nil
 }
 
 // GetAndDelRaw повертає значення з RAM-снапшота та видаляє ключ.
@@ -214,19 +440,16 @@ func (ch *Chunk) GetAndDelRaw(key []byte) (val []byte, exist bool, err error) {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	if ch.memoryData.Data == nil {
-		return nil, false, nil
-	}
-
-	v, ok := ch.memoryData.Data[string(key)]
-	if !ok {
-		return nil, false, nil
+	v, ok, err := ch.lookup(string(key))
+	if err != nil || !ok {
+		return nil, false, err
 	}
 
 	valCopy := make([]byte, len(v))
 	copy(valCopy, v)
 
-	delete(ch.memoryData.Data, string(key))
+	ch.markDirty(string(key))
+	ch.remove(ch.data(string(key), true), string(key))
 	ch.changes = true
 
 	return valCopy, true, nil
@@ -234,39 +457,61 @@ func (ch *Chunk) GetAndDelRaw(key []byte) (val []byte, exist bool, err error) {
 
 // SetRaw записує значення у RAM-снапшот по ключу.
 // Значення копіюється, щоб викликач не міг змінити байти “постфактум” через aliasing.
+// Запис у сторінку, яку не вдалося завантажити, не приймається: помилку повертають Err і SaveChanges
+// (Set повертає її одразу).
 func (ch *Chunk) SetRaw(key, val []byte) {
+	_ = ch.setRaw(key, val)
+}
+
+func (ch *Chunk) setRaw(key, val []byte) error {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	if ch.memoryData.Data == nil {
-		ch.memoryData.Data = make(map[string][]byte)
+	data := ch.data(string(key), true)
+	if data == nil {
+		return ch.err
 	}
 
 	valCopy := make([]byte, len(val))
 	copy(valCopy, val)
-	ch.memoryData.Data[string(key)] = valCopy
+	ch.markDirty(string(key))
+	data[string(key)] = valCopy
 	ch.changes = true
+	return nil
 }
 
 // Del видаляє ключ з RAM-снапшота і встановлює changes=true.
+// Як і SetRaw, не приймає видалення зі сторінки, яку не вдалося завантажити.
 func (ch *Chunk) Del(key []byte) {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	if ch.memoryData.Data == nil {
+	data := ch.data(string(key), true)
+	if data == nil {
 		return
 	}
 
-	delete(ch.memoryData.Data, string(key))
+	ch.markDirty(string(key))
+	ch.remove(data, string(key))
 	ch.changes = true
 }
 
 // Clear очищає RAM-снапшот (видаляє всі ключі) і встановлює changes=true.
+// Для злиття (WithMerge) Clear — це видалення кожного ключа, відомого снапшоту.
 func (ch *Chunk) Clear() {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
+	// для злиття потрібні всі ключі снапшота
+	ch.materialize()
+	for key := range ch.memoryData.Data {
+		ch.markDirty(key)
+	}
 	ch.memoryData.Data = make(map[string][]byte)
+	ch.lazy = nil
+	if ch.opts.pages > 0 {
+		ch.clearPages()
+	}
 	ch.changes = true
 }
 
@@ -276,51 +521,57 @@ func (ch *Chunk) Clear() {
 // Увага: цей метод працює з “сирими” байтами. Якщо ви використовуєте типізовані значення,
 // використовуйте OnSet + Set/Get (msgpack).
 func (ch *Chunk) OnSetRaw(key []byte, fn OnSet) (val []byte, err error) {
-	val, exist := ch.GetRaw(key)
-	if exist {
-		return val, nil
-	}
-
-	val, err = fn()
-	if err != nil {
-		return nil, err
-	}
-
-	ch.SetRaw(key, val)
-	return val, nil
+	return ch.OnSetRawCtx(context.Background(), key, func(context.Context) ([]byte, error) {
+		return fn()
+	})
 }
 
-// getOrCreateChunkRaw читає payload чанку з кешу або повертає порожній ChunkRaw.
-// Payload кодується msgpack. Повернутий ChunkRaw завжди має не-nil Data.
-func (ch *Chunk) getOrCreateChunkRaw() (ChunkRaw, error) {
-	rawData, exist, err := ch.ch.Get(getChunkKey(ch.name))
-	if err != nil {
-		return ChunkRaw{}, err
+// OnSetRawCtx — варіант OnSetRaw, який передає ctx у фабрику значення.
+func (ch *Chunk) OnSetRawCtx(ctx context.Context, key []byte, fn OnSetCtx) (val []byte, err error) {
+	val, exist, err := ch.getRaw(key)
+	if err != nil || exist {
+		return val, err
 	}
+	return ch.loadRaw(ctx, key, fn)
+}
 
-	var chunkData ChunkRaw
-	if exist {
-		dec := msgpack.NewDecoder(bytes.NewReader(rawData))
-		if err := dec.Decode(&chunkData); err != nil {
-			return ChunkRaw{}, err
+// loadRaw викликає fn і записує результат у RAM (SetRaw).
+// Конкурентні виклики для одного ключа обʼєднуються (якщо Cache не створено з WithoutSingleFlight):
+// fn виконується один раз, а решта викликачів отримують копію його результату.
+func (ch *Chunk) loadRaw(ctx context.Context, key []byte, fn OnSetCtx) ([]byte, error) {
+	load := func() ([]byte, error) {
+		// поки ми чекали на свою чергу, ключ міг зʼявитися в RAM
+		if val, exist, err := ch.getRaw(key); err != nil || exist {
+			return val, err
+		}
+		val, err := fn(ctx)
+		if err != nil {
+			return nil, err
 		}
+		if err := ch.setRaw(key, val); err != nil {
+			return nil, err
+		}
+		return val, nil
 	}
-
-	if chunkData.Data == nil {
-		chunkData.Data = make(map[string][]byte)
+	if ch.ch.noSingleFlight {
+		return load()
 	}
 
-	return chunkData, nil
+	val, err, shared := ch.flight.do(ctx, string(key), load)
+	if shared {
+		ch.ch.stats.onSetCoalesced.Add(1)
+	}
+	return val, err
 }
 
-// saveChunkRaw серіалізує ChunkRaw (msgpack) і записує в кеш з TTL чанку.
-func (ch *Chunk) saveChunkRaw(chunkData ChunkRaw) error {
-	var buffer bytes.Buffer
-	enc := msgpack.NewEncoder(&buffer)
-	if err := enc.Encode(chunkData); err != nil {
-		return err
+// getOrCreateChunkRaw читає payload чанку з кешу або повертає порожній ChunkRaw.
+// Повернутий ChunkRaw завжди має не-nil Data.
+func (ch *Chunk) getOrCreateChunkRaw(ctx context.Context) (ChunkRaw, error) {
+	rawData, exist, err := ch.ch.get(ctx, getChunkKey(ch.name))
+	if err != nil {
+		return ChunkRaw{}, err
 	}
-	return ch.ch.Set(getChunkKey(ch.name), buffer.Bytes(), ch.expiriesSecond)
+	return decodeChunkRaw(rawData, exist, ch.opts.codec)
 }
 
 // cloneChunkRaw робить глибоку копію ChunkRaw (map + []byte).
@@ -365,80 +616,136 @@ func cloneChunkMapShallow(src map[string][]byte) map[string][]byte {
 // Алгоритм:
 //  1. Якщо змін не було — повертає nil.
 //  2. Швидка перевірка: читає тільки versionKey і робить fast-fail при розбіжності з baseVersion.
-//  3. Фінальна перевірка: читає payload і перевіряє самоконсистентність (payload.Version vs versionKey)
-//     та збіг з baseVersion.
-//  4. Формує next payload з версією baseVersion+1.
+//     Якщо versionKey зник (TTL/витіснення) — звіряє baseVersion з payload.Version.
+//  3. Формує next payload з версією baseVersion+1.
 //     Для продуктивності копіює лише map (shallow copy), без дублювання []byte.
-//  5. Записує payload, потім versionKey.
-//  6. Оновлює локальний стан (baseVersion/memoryData.Version) і скидає changes.
+//  4. Записує payload і versionKey умовно: лише якщо versionKey досі дорівнює baseVersion.
+//  5. Оновлює локальний стан (baseVersion/memoryData.Version) і скидає changes.
+//
+// Атомарність кроку 4 залежить від драйвера (див. Cache.commitIf):
+//   - TxDriver: перевірка версії, payload і versionKey комітяться разом; з паралельних writer-ів
+//     перемагає рівно один. Badger пише їх в одній транзакції, тож payload і версія не розходяться.
+//     FreeCache лише послідовно пише ключі під смугастим lock-ом: коміт атомарний у межах процесу,
+//     але помилка між записами залишає payload і versionKey розбіжними (Chunk() повертатиме
+//     ErrChunkConflict, доки ключі не зникнуть за TTL);
+//   - AtomicDriver: versionKey змінюється через CAS (перемагає рівно один writer), потім пишеться payload.
+//     Падіння між цими записами залишає versionKey новішим за payload: Chunk() такого чанку
+//     повертатиме ErrChunkConflict, доки ключі не зникнуть за TTL;
+//   - інші драйвери — best effort: перевірка і записи серіалізуються лише в межах процесу,
+//     writer-и з різних процесів можуть перезаписати зміни один одного.
+//
+// У режимі WithMerge конфлікт версій не є фінальним: зміни цього снапшота переносяться
+// на поточний стан чанку по ключах (див. rebase), і коміт повторюється.
 //
 // Повертає ErrChunkConflict, якщо чанк паралельно змінив інший writer.
 func (ch *Chunk) SaveChanges() error {
+	return ch.SaveChangesCtx(context.Background())
+}
+
+// SaveChangesCtx — варіант SaveChanges з context.Context, який передається у драйвер.
+func (ch *Chunk) SaveChangesCtx(ctx context.Context) error {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
+	if ch.err != nil {
+		return ch.err
+	}
 	if !ch.changes {
 		return nil
 	}
-
-	// 1) швидка перевірка: читаємо тільки versionKey
-	verKey, verKeyExist, err := ch.loadVersionKey()
-	if err != nil {
-		return err
+	if ch.materialize(); ch.err != nil {
+		return ch.err
 	}
 
-	// FAST FAIL: якщо версія-ключ існує і вже не збігається з baseVersion — конфлікт.
-	if verKeyExist && verKey != ch.baseVersion {
-		return ErrChunkConflict
+	for attempt := 1; ; attempt++ {
+		err := ch.commit(ctx)
+		if !errors.Is(err, ErrChunkConflict) || !ch.opts.merge || attempt >= mergeAttempts {
+			return err
+		}
+		if err := ch.rebase(ctx); err != nil {
+			return err
+		}
 	}
+}
 
-	// 2/3) payload для самоконсистентності (друга лінія оборони)
-	current, err := ch.getOrCreateChunkRaw()
+// commit — одна спроба optimistic-коміту (кроки 2-5 SaveChanges). Викликається під mu.
+func (ch *Chunk) commit(ctx context.Context) error {
+	// 1) швидка перевірка: читаємо тільки versionKey
+	verKey, verKeyExist, err := ch.loadVersionKey(ctx)
 	if err != nil {
 		return err
 	}
 
-	// 3) самоконсистентність: payload.Version має збігатися з versionKey (коли ключ існує)
-	if verKeyExist && current.Version != verKey {
+	// FAST FAIL: якщо версія-ключ існує і вже не збігається з baseVersion — конфлікт.
+	if verKeyExist && verKey != ch.baseVersion {
 		return ErrChunkConflict
 	}
 
-	// 4) якщо versionKey не існував — ініціалізуємо його з payload.Version
-	if !verKeyExist {
-		if err := ch.saveVersionKey(current.Version); err != nil {
+	// якщо versionKey зник — комітимо лише за його відсутності і якщо стан чанку не змінився
+	var oldVer []byte
+	if verKeyExist {
+		oldVer = encodeVersion(verKey)
+	} else {
+		current, err := ch.readChunk(ctx)
+		if err != nil {
 			return err
 		}
-		verKey = current.Version
-
-		// після ініціалізації: якщо payload.Version не той, з яким ми працювали — конфлікт
-		if verKey != ch.baseVersion {
+		if current.raw.Version != ch.baseVersion {
 			return ErrChunkConflict
 		}
 	}
 
-	// 5) фінальна CAS перевірка по payload
-	if current.Version != ch.baseVersion {
-		return ErrChunkConflict
-	}
-
-	// 6) готуємо новий payload
+	// 3) готуємо записи: повний payload або запис журналу змін (WithDeltaLog)
 	newVer := ch.baseVersion + 1
-	next := ChunkRaw{
-		Version: newVer,
-		Data:    cloneChunkMapShallow(ch.memoryData.Data),
+	items, err := ch.commitItems(ctx, newVer)
+	if err != nil {
+		return err
 	}
 
-	// 7) запис payload -> потім versionKey
-	if err := ch.saveChunkRaw(next); err != nil {
-		return err
+	// 4) умовний запис payload + versionKey
+	versionKey := getChunkVersionKey(ch.name)
+	items[string(versionKey)] = encodeVersion(newVer)
+	ttl := ch.expiriesSecond
+	if ch.opts.deltaLog {
+		ttl = ch.deltaCommitTTL()
 	}
-	if err := ch.saveVersionKey(newVer); err != nil {
+	ok, err := ch.ch.commitIf(ctx, versionKey, oldVer, items, ttl)
+	if err != nil {
 		return err
 	}
+	if !ok {
+		return ErrChunkConflict
+	}
 
-	// 8) оновлюємо локальний стан
+	// 5) оновлюємо локальний стан
 	ch.memoryData.Version = newVer
 	ch.baseVersion = newVer
 	ch.changes = false
+	ch.dirty = nil
+	switch {
+	case ch.opts.deltaLog:
+		ch.afterDeltaCommit(ctx, newVer)
+	case ch.opts.pages > 0:
+		ch.afterPagedCommit(ctx)
+	}
 	return nil
 }
+
+// commitItems формує ключі для запису версії newVer (крім versionKey). Викликається під mu.
+func (ch *Chunk) commitItems(ctx context.Context, newVer uint64) (map[string][]byte, error) {
+	switch {
+	case ch.opts.deltaLog:
+		return ch.deltaCommitItems(newVer)
+	case ch.opts.pages > 0:
+		return ch.pagedCommitItems(ctx, newVer)
+	}
+
+	payload, err := encodeChunkRaw(ChunkRaw{
+		Version: newVer,
+		Data:    cloneChunkMapShallow(ch.memoryData.Data),
+	}, ch.opts.codec)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{string(getChunkKey(ch.name)): payload}, nil
+}
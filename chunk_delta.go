@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// deltaReadAttempts обмежує кількість перечитувань, коли паралельна компакція
+// видалила записи журналу між читанням бази і журналу.
+const deltaReadAttempts = 3
+
+// errDeltaMissing — внутрішній сигнал: запис журналу між базою і versionKey відсутній.
+var errDeltaMissing = errors.New("cache: запис журналу змін чанку відсутній")
+
+// chunkDelta — запис журналу змін WithDeltaLog: ключі, змінені комітом версії Version.
+type chunkDelta struct {
+	Version uint64            `msgpack:"v"`
+	Set     map[string][]byte `msgpack:"s,omitempty"`
+	Del     []string          `msgpack:"d,omitempty"`
+}
+
+// deltaState — стан базового снапшота, прочитаний разом із чанком.
+type deltaState struct {
+	// base — версія базового снапшота (payload чанку).
+	base uint64
+	// meta — сире значення baseKey (nil — ключ відсутній); умова CAS для компакції.
+	meta []byte
+	// writtenAt — unix-час запису бази (0 — невідомий, наприклад чанк у старому форматі).
+	writtenAt int64
+	// needBase — payload відсутній: наступний коміт пише повний базовий снапшот замість запису журналу.
+	needBase bool
+	// pendingMeta — meta бази, яку пише поточний коміт при needBase.
+	pendingMeta []byte
+}
+
+// getChunkBaseKey — ключ метаданих базового снапшота WithDeltaLog.
+func getChunkBaseKey(name string) []byte {
+	return chunkKey(name, chunkPartBase)
+}
+
+// getChunkDeltaKey — ключ запису журналу змін для версії ver.
+func getChunkDeltaKey(name string, ver uint64) []byte {
+	return chunkKeyN(name, chunkPartDelta, ver)
+}
+
+// encodeBaseMeta пакує версію бази і час її запису (2×uint64 LE).
+func encodeBaseMeta(ver uint64, writtenAt int64) []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b, ver)
+	binary.LittleEndian.PutUint64(b[8:], uint64(writtenAt))
+	return b
+}
+
+// decodeBaseMeta повертає час запису бази; пошкоджене значення трактується як невідомий час.
+func decodeBaseMeta(b []byte) int64 {
+	if len(b) != 16 {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64(b[8:]))
+}
+
+// readDeltaChunk відновлює стан чанку з бази і журналу змін.
+// Записи журналу, видалені паралельною компакцією, призводять до перечитування.
+func (ch *Chunk) readDeltaChunk(ctx context.Context) (chunkSnapshot, error) {
+	for attempt := 1; ; attempt++ {
+		snap, err := ch.tryReadDeltaChunk(ctx)
+		if !errors.Is(err, errDeltaMissing) {
+			return snap, err
+		}
+		if attempt >= deltaReadAttempts {
+			return chunkSnapshot{}, ErrChunkCorrupted
+		}
+	}
+}
+
+func (ch *Chunk) tryReadDeltaChunk(ctx context.Context) (chunkSnapshot, error) {
+	versionKey, payloadKey, baseKey := getChunkVersionKey(ch.name), getChunkKey(ch.name), getChunkBaseKey(ch.name)
+	vals, err := ch.ch.getMany(ctx, [][]byte{versionKey, payloadKey, baseKey, getChunkPagesKey(ch.name)})
+	if err != nil {
+		return chunkSnapshot{}, err
+	}
+	if _, ok := vals[string(getChunkPagesKey(ch.name))]; ok {
+		return chunkSnapshot{}, ErrChunkLayout
+	}
+
+	var snap chunkSnapshot
+	if snap.verKey, snap.verKeyExist, err = decodeVersionKey(vals, versionKey); err != nil {
+		return chunkSnapshot{}, err
+	}
+
+	payload, payloadExist := vals[string(payloadKey)]
+	if snap.raw, err = decodeChunkRaw(payload, payloadExist, ch.opts.codec); err != nil {
+		return chunkSnapshot{}, err
+	}
+	if !payloadExist {
+		// бази немає: чанк новий, або базу витіснено — тоді журнал без неї не відновити
+		// (за TTL база не зникає раніше за versionKey і журнал, див. deltaCommitTTL)
+		if _, ok := vals[string(baseKey)]; ok {
+			return chunkSnapshot{}, ErrChunkCorrupted
+		}
+		snap.delta.needBase = true
+		snap.fresh = true
+		if snap.verKeyExist {
+			_, deltaExist, err := ch.ch.get(ctx, getChunkDeltaKey(ch.name, snap.verKey))
+			if err != nil {
+				return chunkSnapshot{}, err
+			}
+			if deltaExist {
+				return chunkSnapshot{}, ErrChunkCorrupted
+			}
+			snap.raw.Version = snap.verKey
+		}
+		return snap, nil
+	}
+
+	snap.delta.base = snap.raw.Version
+	if meta, ok := vals[string(baseKey)]; ok {
+		snap.delta.meta = meta
+		snap.delta.writtenAt = decodeBaseMeta(meta)
+	}
+
+	if !snap.verKeyExist {
+		// versionKey витіснений: застосовуємо журнал, доки є записи
+		for {
+			raw, exist, err := ch.ch.get(ctx, getChunkDeltaKey(ch.name, snap.raw.Version+1))
+			if err != nil {
+				return chunkSnapshot{}, err
+			}
+			if !exist {
+				return snap, nil
+			}
+			if err := applyChunkDelta(&snap.raw, raw); err != nil {
+				return chunkSnapshot{}, err
+			}
+		}
+	}
+	if snap.verKey <= snap.raw.Version {
+		return snap, nil
+	}
+
+	keys := make([][]byte, 0, snap.verKey-snap.raw.Version)
+	for ver := snap.raw.Version + 1; ver <= snap.verKey; ver++ {
+		keys = append(keys, getChunkDeltaKey(ch.name, ver))
+	}
+	deltas, err := ch.ch.getMany(ctx, keys)
+	if err != nil {
+		return chunkSnapshot{}, err
+	}
+	for _, key := range keys {
+		raw, ok := deltas[string(key)]
+		if !ok {
+			return chunkSnapshot{}, errDeltaMissing
+		}
+		if err := applyChunkDelta(&snap.raw, raw); err != nil {
+			return chunkSnapshot{}, err
+		}
+	}
+	return snap, nil
+}
+
+// applyChunkDelta застосовує запис журналу до chunkData і переводить його на наступну версію.
+func applyChunkDelta(chunkData *ChunkRaw, raw []byte) error {
+	var delta chunkDelta
+	if err := msgpack.Unmarshal(raw, &delta); err != nil {
+		return err
+	}
+	if delta.Version != chunkData.Version+1 {
+		return ErrChunkCorrupted
+	}
+	for k, v := range delta.Set {
+		chunkData.Data[k] = v
+	}
+	for _, k := range delta.Del {
+		delete(chunkData.Data, k)
+	}
+	chunkData.Version = delta.Version
+	return nil
+}
+
+// deltaCommitItems формує запис журналу з ключів dirty або, якщо бази немає, повний базовий снапшот.
+// Викликається під mu.
+func (ch *Chunk) deltaCommitItems(newVer uint64) (map[string][]byte, error) {
+	if rest, ok := ch.baseTTL(); ok && rest < 1 {
+		// база от-от зникне: журнал поверх неї не пережив би її, тож пишемо нову базу
+		ch.delta.needBase = true
+	}
+	if ch.delta.needBase {
+		payload, err := encodeChunkRaw(ChunkRaw{
+			Version: newVer,
+			Data:    cloneChunkMapShallow(ch.memoryData.Data),
+		}, ch.opts.codec)
+		if err != nil {
+			return nil, err
+		}
+		ch.delta.pendingMeta = encodeBaseMeta(newVer, ch.ch.now().Unix())
+		return map[string][]byte{
+			string(getChunkKey(ch.name)):     payload,
+			string(getChunkBaseKey(ch.name)): ch.delta.pendingMeta,
+		}, nil
+	}
+
+	delta := chunkDelta{Version: newVer}
+	for key, base := range ch.dirty {
+		if val, ok := ch.memoryData.Data[key]; ok {
+			if delta.Set == nil {
+				delta.Set = make(map[string][]byte)
+			}
+			delta.Set[key] = val
+		} else if base.exist {
+			delta.Del = append(delta.Del, key)
+		}
+	}
+	raw, err := msgpack.Marshal(delta)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{string(getChunkDeltaKey(ch.name, newVer)): raw}, nil
+}
+
+// baseTTL повертає залишковий TTL базового снапшота в секундах; false — TTL невідомий
+// (чанк без TTL, база ще не записана або записана без метаданих). Викликається під mu.
+func (ch *Chunk) baseTTL() (int64, bool) {
+	if ch.expiriesSecond <= 0 || ch.delta.needBase || ch.delta.writtenAt == 0 {
+		return 0, false
+	}
+	return ch.delta.writtenAt + int64(ch.expiriesSecond) - ch.ch.now().Unix(), true
+}
+
+// deltaCommitTTL — TTL запису журналу і versionKey: не більше за залишок TTL бази,
+// тож versionKey і журнал не переживають базу, без якої їх не відновити. Викликається під mu.
+func (ch *Chunk) deltaCommitTTL() int {
+	if rest, ok := ch.baseTTL(); ok && rest < int64(ch.expiriesSecond) {
+		return int(rest)
+	}
+	return ch.expiriesSecond
+}
+
+// deltaLogKeys повертає ключі записів журналу змін чанку name, які можуть існувати:
+// між базою і versionKey, а якщо одного з них немає — суцільний ряд записів від відомого краю.
+func (ch *Cache) deltaLogKeys(ctx context.Context, name string) ([][]byte, error) {
+	versionKey, baseKey := getChunkVersionKey(name), getChunkBaseKey(name)
+	vals, err := ch.getMany(ctx, [][]byte{versionKey, baseKey})
+	if err != nil {
+		return nil, err
+	}
+	verKey, verKeyExist, err := decodeVersionKey(vals, versionKey)
+	if err != nil {
+		return nil, err
+	}
+	meta, baseExist := vals[string(baseKey)]
+	baseExist = baseExist && len(meta) == 16
+	base := uint64(0)
+	if baseExist {
+		base = binary.LittleEndian.Uint64(meta)
+	}
+
+	switch {
+	case verKeyExist && baseExist:
+		var keys [][]byte
+		for ver := base + 1; ver <= verKey; ver++ {
+			keys = append(keys, getChunkDeltaKey(name, ver))
+		}
+		return keys, nil
+	case baseExist:
+		return ch.existingDeltas(ctx, name, base+1, 1)
+	case verKeyExist:
+		return ch.existingDeltas(ctx, name, verKey, -1)
+	}
+	return nil, nil
+}
+
+// existingDeltas збирає ключі наявних записів журналу, починаючи з версії from у напрямку step (±1),
+// до першого відсутнього запису.
+func (ch *Cache) existingDeltas(ctx context.Context, name string, from uint64, step int) ([][]byte, error) {
+	const batch = 16
+	var out [][]byte
+	for ver := from; ver > 0; {
+		keys := make([][]byte, 0, batch)
+		for i := 0; i < batch && ver > 0; i++ {
+			keys = append(keys, getChunkDeltaKey(name, ver))
+			ver += uint64(step)
+		}
+		found, err := ch.getMany(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if _, ok := found[string(key)]; !ok {
+				return out, nil
+			}
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// afterDeltaCommit оновлює стан бази після успішного коміту і за потреби стискає журнал.
+// Викликається під mu.
+func (ch *Chunk) afterDeltaCommit(ctx context.Context, newVer uint64) {
+	now := ch.ch.now().Unix()
+	if ch.delta.needBase {
+		ch.delta = deltaState{base: newVer, meta: ch.delta.pendingMeta, writtenAt: now}
+		return
+	}
+
+	pending := newVer - ch.delta.base
+	expiring := ch.expiriesSecond > 0 && now-ch.delta.writtenAt >= int64(ch.expiriesSecond)/2
+	if pending < uint64(ch.opts.compactEvery) && !expiring {
+		return
+	}
+	// компакція — best effort: якщо не вдалася, журнал просто залишається довшим
+	_ = ch.compact(ctx, newVer, now)
+}
+
+// compact записує поточний RAM-снапшот (версії ver) як нову базу і видаляє поглинуті записи журналу.
+// Умова — незмінна meta бази, тож з паралельних компакцій перемагає одна. Викликається під mu.
+func (ch *Chunk) compact(ctx context.Context, ver uint64, now int64) error {
+	payload, err := encodeChunkRaw(ChunkRaw{
+		Version: ver,
+		Data:    cloneChunkMapShallow(ch.memoryData.Data),
+	}, ch.opts.codec)
+	if err != nil {
+		return err
+	}
+	baseKey := getChunkBaseKey(ch.name)
+	meta := encodeBaseMeta(ver, now)
+	ok, err := ch.ch.commitIf(ctx, baseKey, ch.delta.meta, map[string][]byte{
+		string(getChunkKey(ch.name)): payload,
+		string(baseKey):              meta,
+	}, ch.expiriesSecond)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrChunkConflict
+	}
+
+	keys := make([][]byte, 0, ver-ch.delta.base)
+	for v := ch.delta.base + 1; v <= ver; v++ {
+		keys = append(keys, getChunkDeltaKey(ch.name, v))
+	}
+	ch.delta = deltaState{base: ver, meta: meta, writtenAt: now}
+	return ch.ch.delMany(ctx, keys)
+}
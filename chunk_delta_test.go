@@ -0,0 +1,237 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/drivers"
+)
+
+// countingDriver рахує читання, записи і видалення, що доходять до драйвера.
+type countingDriver struct {
+	cache.CacheDriver
+
+	gets     atomic.Int64
+	setBytes atomic.Int64
+	dels     atomic.Int64
+}
+
+func (d *countingDriver) Get(key []byte) ([]byte, bool, error) {
+	d.gets.Add(1)
+	return d.CacheDriver.Get(key)
+}
+
+func (d *countingDriver) Set(key, val []byte, expiriesSecond int) error {
+	d.setBytes.Add(int64(len(val)))
+	return d.CacheDriver.Set(key, val, expiriesSecond)
+}
+
+func (d *countingDriver) Del(key []byte) error {
+	d.dels.Add(1)
+	return d.CacheDriver.Del(key)
+}
+
+func openDelta(t *testing.T, c *cache.Cache, name string, compactEvery int) *cache.Chunk {
+	t.Helper()
+	ch, err := c.Chunk(name, 60, cache.WithDeltaLog(compactEvery))
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	return ch
+}
+
+func TestChunkDeltaLogCommitSize(t *testing.T) {
+	const name = "chunk_delta_size"
+	dr := &countingDriver{CacheDriver: newFreeCacheDriver()}
+	c := cache.NewCache(dr)
+
+	ch := openDelta(t, c, name, 0)
+	for i := 0; i < 400; i++ {
+		ch.SetRaw([]byte(fmt.Sprintf("key-%d", i)), []byte("v"))
+	}
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() base: %v", err)
+	}
+	baseBytes := dr.setBytes.Load()
+
+	ch = openDelta(t, c, name, 0)
+	ch.SetRaw([]byte("key-7"), []byte("changed"))
+	ch.Del([]byte("key-8"))
+	dr.setBytes.Store(0)
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() delta: %v", err)
+	}
+	if got := dr.setBytes.Load(); got*20 > baseBytes {
+		t.Fatalf("delta commit wrote %d bytes, base snapshot was %d", got, baseBytes)
+	}
+
+	ch = openDelta(t, c, name, 0)
+	if got, _ := ch.GetRaw([]byte("key-7")); string(got) != "changed" {
+		t.Fatalf("key-7: expected changed, got %q", got)
+	}
+	if _, exist := ch.GetRaw([]byte("key-8")); exist {
+		t.Fatalf("key-8: expected deleted")
+	}
+	if got, _ := ch.GetRaw([]byte("key-399")); string(got) != "v" {
+		t.Fatalf("key-399: expected base value, got %q", got)
+	}
+}
+
+func TestChunkDeltaLogCompaction(t *testing.T) {
+	testChunkDeltaLogCompaction(t, newFreeCacheDriver())
+
+	dr, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	defer dr.Close()
+	testChunkDeltaLogCompaction(t, dr)
+}
+
+func testChunkDeltaLogCompaction(t *testing.T, inner cache.CacheDriver) {
+	const (
+		name    = "chunk_delta_compact"
+		commits = 11
+	)
+	dr := &countingDriver{CacheDriver: inner}
+	c := cache.NewCache(dr)
+
+	for i := 1; i <= commits; i++ {
+		ch := openDelta(t, c, name, 4)
+		ch.SetRaw([]byte(fmt.Sprintf("k%d", i)), []byte(fmt.Sprint(i)))
+		ch.Del([]byte(fmt.Sprintf("k%d", i-1)))
+		if err := ch.SaveChanges(); err != nil {
+			t.Fatalf("SaveChanges() #%d: %v", i, err)
+		}
+	}
+
+	ch := openDelta(t, c, name, 4)
+	for i := 1; i < commits; i++ {
+		if _, exist := ch.GetRaw([]byte(fmt.Sprintf("k%d", i))); exist {
+			t.Fatalf("k%d: expected deleted", i)
+		}
+	}
+	if got, _ := ch.GetRaw([]byte(fmt.Sprintf("k%d", commits))); string(got) != fmt.Sprint(commits) {
+		t.Fatalf("k%d: expected %d, got %q", commits, commits, got)
+	}
+
+	// компакції на версіях 4 і 8 видаляють поглинуті записи журналу 1..8
+	if dels := dr.dels.Load(); dels != 8 {
+		t.Fatalf("expected 8 compacted deltas to be deleted, got %d", dels)
+	}
+}
+
+func TestChunkDeltaLogUpgradesLegacyChunk(t *testing.T) {
+	const name = "chunk_delta_legacy"
+	c := newFreeCache()
+
+	legacy, err := c.Chunk(name, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	legacy.SetRaw([]byte("old"), []byte("1"))
+	if err := legacy.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() legacy: %v", err)
+	}
+
+	ch := openDelta(t, c, name, 0)
+	if got, _ := ch.GetRaw([]byte("old")); string(got) != "1" {
+		t.Fatalf("old: expected 1, got %q", got)
+	}
+	ch.SetRaw([]byte("new"), []byte("2"))
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() delta: %v", err)
+	}
+
+	ch = openDelta(t, c, name, 0)
+	if got, _ := ch.GetRaw([]byte("old")); string(got) != "1" {
+		t.Fatalf("old after upgrade: expected 1, got %q", got)
+	}
+	if got, _ := ch.GetRaw([]byte("new")); string(got) != "2" {
+		t.Fatalf("new after upgrade: expected 2, got %q", got)
+	}
+}
+
+// chunkStorageKey повторює розкладку службового ключа частини part чанку name (імʼя коротше 128 байт).
+func chunkStorageKey(name string, part byte) []byte {
+	return append(append([]byte{0x00, 0x01, 'c', byte(len(name))}, name...), part)
+}
+
+func TestChunkDeltaLogDeleteChunk(t *testing.T) {
+	const name = "chunk_delta_delete"
+	dr := newFreeCacheDriver()
+	c := cache.NewCache(dr)
+
+	for i := 1; i <= 3; i++ {
+		ch := openDelta(t, c, name, 64)
+		ch.SetRaw([]byte(fmt.Sprintf("old%d", i)), []byte("x"))
+		if err := ch.SaveChanges(); err != nil {
+			t.Fatalf("SaveChanges() #%d: %v", i, err)
+		}
+	}
+	if err := c.DeleteChunk(name); err != nil {
+		t.Fatalf("DeleteChunk(): %v", err)
+	}
+
+	ch := openDelta(t, c, name, 64)
+	ch.SetRaw([]byte("new"), []byte("y"))
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() recreated: %v", err)
+	}
+
+	// versionKey витіснено: журнал застосовується, доки є записи, — старих записів бути не повинно
+	if err := dr.Del(chunkStorageKey(name, 'v')); err != nil {
+		t.Fatalf("Del(versionKey): %v", err)
+	}
+	ch = openDelta(t, c, name, 64)
+	if keys, err := ch.Keys(); err != nil || len(keys) != 1 || string(keys[0]) != "new" {
+		t.Fatalf("recreated chunk: expected only \"new\", got %q, %v", keys, err)
+	}
+}
+
+func TestChunkDeltaLogBaseTTL(t *testing.T) {
+	const name = "chunk_delta_base_ttl"
+	dr := newFreeCacheDriver()
+	now := time.Now()
+	c := cache.NewCache(dr, cache.WithClock(func() time.Time { return now }))
+
+	open := func() *cache.Chunk {
+		t.Helper()
+		ch, err := c.Chunk(name, 10, cache.WithDeltaLog(64))
+		if err != nil {
+			t.Fatalf("Chunk(): %v", err)
+		}
+		return ch
+	}
+
+	ch := open()
+	ch.SetRaw([]byte("a"), []byte("1"))
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() base: %v", err)
+	}
+
+	// базі 4 секунди з 10: versionKey і запис журналу не мають пережити її
+	now = now.Add(4 * time.Second)
+	ch = open()
+	ch.SetRaw([]byte("b"), []byte("2"))
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() delta: %v", err)
+	}
+	_, ttl, exist, err := dr.(drivers.TTLDriver).GetWithTTL(context.Background(), chunkStorageKey(name, 'v'))
+	if err != nil || !exist || ttl > 6 {
+		t.Fatalf("versionKey ttl=%d exist=%v err=%v, want <= 6", ttl, exist, err)
+	}
+
+	// база зникла раніше за versionKey (витіснення) — це пошкодження, а не порожній чанк
+	if err := dr.Del(chunkStorageKey(name, 'p')); err != nil {
+		t.Fatalf("Del(payload): %v", err)
+	}
+	if _, err := c.Chunk(name, 10, cache.WithDeltaLog(64)); !errors.Is(err, cache.ErrChunkCorrupted) {
+		t.Fatalf("Chunk() without base: expected ErrChunkCorrupted, got %v", err)
+	}
+}
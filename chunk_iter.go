@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// Len повертає кількість ключів у RAM-снапшоті.
+// Якщо частину снапшота не вдалося завантажити (див. Err), повертає цю помилку.
+func (ch *Chunk) Len() (int, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	n := 0
+	if err := ch.walkData(func(string, []byte) { n++ }); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Keys повертає відсортовані копії ключів RAM-снапшота (помилки — як у Len).
+func (ch *Chunk) Keys() ([][]byte, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	keys, err := ch.sortedKeys("")
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(keys))
+	for i, key := range keys {
+		out[i] = []byte(key)
+	}
+	return out, nil
+}
+
+// All повертає ітератор по ключах RAM-снапшота у відсортованому порядку.
+//
+// Набір ключів фіксується під mu на початку ітерації, тож тіло циклу може викликати методи Chunk
+// (зміни, зроблені під час ітерації, у ній не видно). Ключі та значення — копії.
+// Якщо частину снапшота не вдалося завантажити, ітератор не повертає жодного ключа,
+// а помилку повертає Err: неповного набору ключів ітерація не бачить.
+func (ch *Chunk) All() iter.Seq2[[]byte, []byte] {
+	return ch.ScanPrefix(nil)
+}
+
+// Range викликає fn для кожного ключа RAM-снапшота у відсортованому порядку, доки fn повертає true.
+// Повертає помилку завантаження снапшота (див. Err), тоді fn не викликається.
+func (ch *Chunk) Range(fn func(key, val []byte) bool) error {
+	for key, val := range ch.All() {
+		if !fn(key, val) {
+			break
+		}
+	}
+	return ch.Err()
+}
+
+// ScanPrefix повертає ітератор по ключах з префіксом prefix у відсортованому порядку (див. All).
+func (ch *Chunk) ScanPrefix(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		ch.mu.Lock()
+		keys, err := ch.sortedKeys(string(prefix))
+		if err != nil {
+			ch.mu.Unlock()
+			return
+		}
+		vals := make([][]byte, len(keys))
+		for i, key := range keys {
+			// снапшот уже завантажено повністю, тож lookup не повертає помилок;
+			// значення в RAM не мутуються на місці (SetRaw замінює слайс), тож посилань достатньо
+			vals[i], _, _ = ch.lookup(key)
+		}
+		ch.mu.Unlock()
+
+		for i, key := range keys {
+			if !yield([]byte(key), bytes.Clone(vals[i])) {
+				return
+			}
+		}
+	}
+}
+
+// sortedKeys повертає відсортовані ключі снапшота з префіксом prefix. Викликається під mu.
+func (ch *Chunk) sortedKeys(prefix string) ([]string, error) {
+	var keys []string
+	err := ch.walkData(func(key string, _ []byte) {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// walkData обходить усі ключі снапшота. Викликається під mu.
+//
+// Для WithPages завантажує всі сторінки, для WithLazyLoad повністю декодує payload.
+// Якщо щось не вдалося завантажити, обхід зупиняється і повертає "липку" помилку (див. Err).
+func (ch *Chunk) walkData(fn func(key string, val []byte)) error {
+	if ch.opts.pages == 0 {
+		if ch.materialize(); ch.err != nil {
+			return ch.err
+		}
+		for key, val := range ch.memoryData.Data {
+			fn(key, val)
+		}
+		return nil
+	}
+	for i := range ch.pages.data {
+		if ch.loadPage(context.Background(), i); ch.err != nil {
+			return ch.err
+		}
+		for key, val := range ch.pages.data[i] {
+			fn(key, val)
+		}
+	}
+	return nil
+}
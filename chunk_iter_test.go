@@ -0,0 +1,146 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestChunkEnumeration(t *testing.T) {
+	for name, opts := range map[string][]cache.ChunkOption{
+		"plain": nil,
+		"paged": {cache.WithPages(4)},
+		"lazy":  {cache.WithLazyLoad()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testChunkEnumeration(t, newFreeCache(), opts...)
+		})
+	}
+}
+
+func testChunkEnumeration(t *testing.T, c *cache.Cache, opts ...cache.ChunkOption) {
+	const name = "chunk_enum"
+
+	seed, err := c.Chunk(name, 60, opts...)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	for _, key := range []string{"user:2", "user:1", "order:1", "user:10"} {
+		seed.SetRaw([]byte(key), []byte("v-"+key))
+	}
+	if err := seed.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	ch, err := c.Chunk(name, 60, opts...)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	ch.SetRaw([]byte("user:3"), []byte("v-user:3"))
+	ch.Del([]byte("order:1"))
+
+	if n, err := ch.Len(); err != nil || n != 4 {
+		t.Fatalf("Len(): expected 4, got %d, %v", n, err)
+	}
+
+	want := []string{"user:1", "user:10", "user:2", "user:3"}
+	rawKeys, err := ch.Keys()
+	if err != nil {
+		t.Fatalf("Keys(): %v", err)
+	}
+	var keys []string
+	for _, key := range rawKeys {
+		keys = append(keys, string(key))
+	}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("Keys(): expected %v, got %v", want, keys)
+	}
+
+	keys = keys[:0]
+	for key, val := range ch.All() {
+		if string(val) != "v-"+string(key) {
+			t.Fatalf("All(): %q has value %q", key, val)
+		}
+		// значення — копії, а тіло циклу може звертатися до чанку
+		val[0] = 'X'
+		ch.SetRaw([]byte("added-during-iteration"), []byte("1"))
+		keys = append(keys, string(key))
+	}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("All(): expected %v, got %v", want, keys)
+	}
+	if got, _ := ch.GetRaw([]byte("user:1")); string(got) != "v-user:1" {
+		t.Fatalf("All() returned internal slice: user:1=%q", got)
+	}
+
+	var prefixed []string
+	for key := range ch.ScanPrefix([]byte("user:1")) {
+		prefixed = append(prefixed, string(key))
+	}
+	if !slices.Equal(prefixed, []string{"user:1", "user:10"}) {
+		t.Fatalf("ScanPrefix(): got %v", prefixed)
+	}
+
+	visited := 0
+	if err := ch.Range(func(key, val []byte) bool {
+		visited++
+		return visited < 2
+	}); err != nil {
+		t.Fatalf("Range(): %v", err)
+	}
+	if visited != 2 {
+		t.Fatalf("Range(): expected to stop after 2 keys, visited %d", visited)
+	}
+	if err := ch.Err(); err != nil {
+		t.Fatalf("Err(): %v", err)
+	}
+}
+
+// Сторінка, яку не вдалося завантажити, не дає неповного переліку ключів.
+func TestChunkEnumerationPageError(t *testing.T) {
+	const name = "chunk_enum_conflict"
+	c := newFreeCache()
+
+	seed, err := c.Chunk(name, 60, cache.WithPages(4))
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	for i := 0; i < 16; i++ {
+		seed.SetRaw([]byte(fmt.Sprintf("k%d", i)), []byte("v"))
+	}
+	if err := seed.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	a, err := c.Chunk(name, 60, cache.WithPages(4))
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	b, err := c.Chunk(name, 60, cache.WithPages(4))
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	b.SetRaw([]byte("k0"), []byte("B"))
+	if err := b.SaveChanges(); err != nil {
+		t.Fatalf("B.SaveChanges(): %v", err)
+	}
+
+	if n, err := a.Len(); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("Len(): expected ErrChunkConflict, got %d, %v", n, err)
+	}
+	if keys, err := a.Keys(); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("Keys(): expected ErrChunkConflict, got %q, %v", keys, err)
+	}
+	for key := range a.All() {
+		t.Fatalf("All(): unexpected key %q from a partially loaded snapshot", key)
+	}
+	if err := a.Range(func(key, _ []byte) bool {
+		t.Fatalf("Range(): unexpected key %q from a partially loaded snapshot", key)
+		return true
+	}); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("Range(): expected ErrChunkConflict, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// lazySpan — межі msgpack-значення ключа всередині payload.
+type lazySpan struct {
+	start, end int
+}
+
+// lazyPayload — payload чанку, який декодується на вимогу (WithLazyLoad).
+type lazyPayload struct {
+	raw []byte
+	// index — зміщення значень у raw; nil — індекс ще не побудовано.
+	index map[string]lazySpan
+	// removed — ключі payload, видалені снапшотом.
+	removed map[string]struct{}
+}
+
+func (lp *lazyPayload) remove(key string) {
+	if lp.removed == nil {
+		lp.removed = make(map[string]struct{})
+	}
+	lp.removed[key] = struct{}{}
+}
+
+// decodeLazyVersion читає з payload лише поля Version і Codec, пропускаючи Data без декодування.
+// Payload іншого кодека дає ErrChunkCodec.
+func decodeLazyVersion(raw []byte) (uint64, error) {
+	var (
+		ver   uint64
+		codec string
+	)
+	err := walkChunkRaw(raw, func(dec *msgpack.Decoder, field string) error {
+		var err error
+		switch field {
+		case "Version":
+			ver, err = dec.DecodeUint64()
+		case "Codec":
+			codec, err = dec.DecodeString()
+		default:
+			err = dec.Skip()
+		}
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrChunkCodec, err)
+	}
+	if codec != "" {
+		return 0, ErrChunkCodec
+	}
+	return ver, nil
+}
+
+// buildLazyIndex проходить payload один раз і запамʼятовує зміщення значення кожного ключа Data.
+// Самі значення не декодуються і не копіюються.
+func buildLazyIndex(raw []byte) (map[string]lazySpan, error) {
+	r := bytes.NewReader(raw)
+	var index map[string]lazySpan
+	err := walkChunkRawReader(r, func(dec *msgpack.Decoder, field string) error {
+		if field != "Data" {
+			return dec.Skip()
+		}
+		n, err := dec.DecodeMapLen()
+		if err != nil {
+			return err
+		}
+		index = make(map[string]lazySpan, max(n, 0))
+		for i := 0; i < n; i++ {
+			key, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			start := len(raw) - r.Len()
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+			index[key] = lazySpan{start: start, end: len(raw) - r.Len()}
+		}
+		return nil
+	})
+	if index == nil {
+		index = make(map[string]lazySpan)
+	}
+	return index, err
+}
+
+func walkChunkRaw(raw []byte, fn func(dec *msgpack.Decoder, field string) error) error {
+	return walkChunkRawReader(bytes.NewReader(raw), fn)
+}
+
+// walkChunkRawReader обходить поля закодованого ChunkRaw; fn має прочитати або пропустити значення поля.
+// bytes.Reader читається декодером напряму, без буферизації, тож r.Len() дає поточне зміщення.
+func walkChunkRawReader(r *bytes.Reader, fn func(dec *msgpack.Decoder, field string) error) error {
+	dec := msgpack.NewDecoder(r)
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		field, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		if err := fn(dec, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lazyGet читає ключ з ще не декодованого payload. Перший виклик будує індекс.
+// Помилка декодування стає "липкою" (див. Err) і повертається викликачу. Викликається під mu.
+func (ch *Chunk) lazyGet(key string) ([]byte, bool, error) {
+	lp := ch.lazy
+	if ch.err != nil {
+		return nil, false, ch.err
+	}
+	if _, ok := lp.removed[key]; ok {
+		return nil, false, nil
+	}
+	if lp.index == nil {
+		index, err := buildLazyIndex(lp.raw)
+		if err != nil {
+			ch.err = err
+			return nil, false, err
+		}
+		lp.index = index
+	}
+
+	span, ok := lp.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+	var val []byte
+	if err := msgpack.Unmarshal(lp.raw[span.start:span.end], &val); err != nil {
+		ch.err = err
+		return nil, false, err
+	}
+	if val == nil {
+		val = []byte{}
+	}
+	return val, true, nil
+}
+
+// materialize декодує payload повністю і накладає на нього зміни снапшота,
+// після чого чанк працює як звичайний. Викликається під mu.
+func (ch *Chunk) materialize() {
+	lp := ch.lazy
+	if lp == nil || ch.err != nil {
+		return
+	}
+	full, err := decodeChunkRaw(lp.raw, true, CodecMsgpack)
+	if err != nil {
+		ch.err = err
+		return
+	}
+	for key := range lp.removed {
+		delete(full.Data, key)
+	}
+	for key, val := range ch.memoryData.Data {
+		full.Data[key] = val
+	}
+	ch.memoryData.Data = full.Data
+	ch.lazy = nil
+}
@@ -0,0 +1,135 @@
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestChunkLazyLoad(t *testing.T) {
+	testChunkLazyLoad(t, newFreeCache())
+	testChunkLazyLoad(t, newBadgerCache(t))
+}
+
+func testChunkLazyLoad(t *testing.T, c *cache.Cache) {
+	const name = "chunk_lazy"
+
+	seed, err := c.Chunk(name, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		if err := seed.Set([]byte(fmt.Sprintf("key-%d", i)), testObj{A: i, B: "b"}); err != nil {
+			t.Fatalf("Set(): %v", err)
+		}
+	}
+	seed.SetRaw([]byte("empty"), []byte{})
+	if err := seed.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() seed: %v", err)
+	}
+
+	ch, err := c.Chunk(name, 60, cache.WithLazyLoad())
+	if err != nil {
+		t.Fatalf("Chunk() lazy: %v", err)
+	}
+	var obj testObj
+	if exist, err := ch.Get([]byte("key-123"), &obj); err != nil || !exist || obj.A != 123 {
+		t.Fatalf("Get(key-123): exist=%v err=%v obj=%+v", exist, err, obj)
+	}
+	if got, exist := ch.GetRaw([]byte("empty")); !exist || len(got) != 0 {
+		t.Fatalf("GetRaw(empty): exist=%v got=%q", exist, got)
+	}
+	if _, exist := ch.GetRaw([]byte("missing")); exist {
+		t.Fatalf("GetRaw(missing): expected miss")
+	}
+
+	// зміни накладаються поверх недекодованого payload
+	ch.SetRaw([]byte("new"), []byte("N"))
+	ch.Del([]byte("key-1"))
+	if _, exist, err := ch.GetAndDelRaw([]byte("key-2")); err != nil || !exist {
+		t.Fatalf("GetAndDelRaw(key-2): exist=%v err=%v", exist, err)
+	}
+	for _, key := range []string{"key-1", "key-2"} {
+		if _, exist := ch.GetRaw([]byte(key)); exist {
+			t.Fatalf("%s: expected deleted in lazy snapshot", key)
+		}
+	}
+	ch.SetRaw([]byte("key-1"), []byte("again"))
+	if err := ch.Err(); err != nil {
+		t.Fatalf("Err(): %v", err)
+	}
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() lazy: %v", err)
+	}
+
+	expectRaw(t, c, name, "new", "N")
+	expectRaw(t, c, name, "key-1", "again")
+	expectRaw(t, c, name, "key-2", "")
+
+	full, err := c.Chunk(name, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	if exist, err := full.Get([]byte("key-299"), &obj); err != nil || !exist || obj.A != 299 {
+		t.Fatalf("Get(key-299) after lazy commit: exist=%v err=%v obj=%+v", exist, err, obj)
+	}
+}
+
+func TestChunkLazyLoadClear(t *testing.T) {
+	const name = "chunk_lazy_clear"
+	c := newFreeCache()
+
+	seed, err := c.Chunk(name, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	seed.SetRaw([]byte("a"), []byte("1"))
+	if err := seed.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	ch, err := c.Chunk(name, 60, cache.WithLazyLoad())
+	if err != nil {
+		t.Fatalf("Chunk() lazy: %v", err)
+	}
+	ch.Clear()
+	if _, exist := ch.GetRaw([]byte("a")); exist {
+		t.Fatalf("a: expected cleared")
+	}
+	ch.SetRaw([]byte("b"), []byte("2"))
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+	expectRaw(t, c, name, "a", "")
+	expectRaw(t, c, name, "b", "2")
+}
+
+func TestChunkLazyLoadDecodeError(t *testing.T) {
+	const name = "chunk_lazy_broken"
+	dr := newFreeCacheDriver()
+	c := cache.NewCache(dr)
+
+	// Version читається, а Data не є map: помилку видно лише при побудові індексу
+	payload, err := msgpack.Marshal(map[string]any{"Version": uint64(0), "Data": "broken"})
+	if err != nil {
+		t.Fatalf("msgpack.Marshal(): %v", err)
+	}
+	if err := dr.Set(chunkStorageKey(name, 'p'), payload, 60); err != nil {
+		t.Fatalf("Set(payload): %v", err)
+	}
+
+	ch, err := c.Chunk(name, 60, cache.WithLazyLoad())
+	if err != nil {
+		t.Fatalf("Chunk() lazy: %v", err)
+	}
+	if _, exist := ch.GetRaw([]byte("a")); exist || ch.Err() == nil {
+		t.Fatalf("GetRaw(): expected decode error, got exist=%v err=%v", exist, ch.Err())
+	}
+	var v string
+	if exist, err := ch.Get([]byte("a"), &v); exist || err == nil || err != ch.Err() {
+		t.Fatalf("Get(): expected the sticky decode error, got exist=%v err=%v", exist, err)
+	}
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+)
+
+// mergeAttempts обмежує кількість циклів rebase+commit в одному SaveChanges.
+const mergeAttempts = 8
+
+// dirtyEntry — базове значення ключа на момент першої зміни в снапшоті.
+type dirtyEntry struct {
+	val   []byte
+	exist bool
+}
+
+// MergeConflict описує ключ, який змінили обидва writer-и.
+// Base — значення на момент завантаження снапшота, Ours — значення в цьому снапшоті,
+// Theirs — поточне значення в кеші. *Exist=false означає, що ключа немає (видалений).
+type MergeConflict struct {
+	Key []byte
+
+	Base, Ours, Theirs                []byte
+	BaseExist, OursExist, TheirsExist bool
+}
+
+// MergeResolver вирішує конфлікт одного ключа: повертає підсумкове значення
+// (exist=false — ключ видаляється) або помилку, яка перериває SaveChanges.
+type MergeResolver func(c MergeConflict) (val []byte, exist bool, err error)
+
+// markDirty запамʼятовує базове значення ключа перед першою зміною. Викликається під mu.
+// Значення в memoryData ніколи не мутуються на місці (SetRaw замінює слайс), тож base можна не копіювати.
+func (ch *Chunk) markDirty(key string) {
+	if _, ok := ch.dirty[key]; ok {
+		return
+	}
+	if ch.dirty == nil {
+		ch.dirty = make(map[string]dirtyEntry)
+	}
+	val, exist, _ := ch.lookup(key)
+	ch.dirty[key] = dirtyEntry{val: val, exist: exist}
+}
+
+// rebase переносить зміни снапшота на поточний стан чанку (three-way merge по ключах).
+// Викликається під mu.
+//
+// Для кожного зміненого ключа:
+//   - якщо інший writer його не чіпав (theirs == base) — береться наше значення;
+//   - якщо обидва дійшли до однакового значення — конфлікту немає;
+//   - інакше викликається resolver (або повертається ErrChunkConflict).
+//
+// Незмінені нами ключі беруться з поточного стану. Після rebase поточний стан стає базою:
+// baseVersion і базові значення dirty оновлюються, щоб наступна спроба коміту була умовною вже від нього.
+func (ch *Chunk) rebase(ctx context.Context) error {
+	snap, err := ch.readChunk(ctx)
+	if err != nil {
+		return err
+	}
+	// payload і versionKey розійшлися (коміт "посередині") — нехай викликач повторить пізніше
+	if snap.verKeyExist && snap.raw.Version != snap.verKey {
+		return ErrChunkConflict
+	}
+	current := snap.raw
+
+	merged := current.Data
+	dirty := make(map[string]dirtyEntry, len(ch.dirty))
+	for key, base := range ch.dirty {
+		ours, oursExist := ch.memoryData.Data[key]
+		theirs, theirsExist := current.Data[key]
+
+		val, exist := ours, oursExist
+		switch {
+		case sameValue(theirs, theirsExist, base.val, base.exist):
+		case sameValue(theirs, theirsExist, ours, oursExist):
+		default:
+			if ch.opts.resolver == nil {
+				return ErrChunkConflict
+			}
+			val, exist, err = ch.opts.resolver(MergeConflict{
+				Key:         []byte(key),
+				Base:        base.val,
+				Ours:        ours,
+				Theirs:      theirs,
+				BaseExist:   base.exist,
+				OursExist:   oursExist,
+				TheirsExist: theirsExist,
+			})
+			if err != nil {
+				return err
+			}
+			val = bytes.Clone(val)
+		}
+
+		if exist {
+			merged[key] = val
+		} else {
+			delete(merged, key)
+		}
+		dirty[key] = dirtyEntry{val: theirs, exist: theirsExist}
+	}
+
+	ch.memoryData = ChunkRaw{Version: current.Version, Data: merged}
+	ch.baseVersion = current.Version
+	ch.delta = snap.delta
+	ch.dirty = dirty
+	return nil
+}
+
+func sameValue(a []byte, aExist bool, b []byte, bExist bool) bool {
+	return aExist == bExist && (!aExist || bytes.Equal(a, b))
+}
@@ -0,0 +1,155 @@
+package cache_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+// openPair відкриває два незалежні снапшоти одного чанку.
+func openPair(t *testing.T, c *cache.Cache, name string, opts ...cache.ChunkOption) (*cache.Chunk, *cache.Chunk) {
+	t.Helper()
+	a, err := c.Chunk(name, 60, opts...)
+	if err != nil {
+		t.Fatalf("Chunk() A: %v", err)
+	}
+	b, err := c.Chunk(name, 60, opts...)
+	if err != nil {
+		t.Fatalf("Chunk() B: %v", err)
+	}
+	return a, b
+}
+
+func expectRaw(t *testing.T, c *cache.Cache, name, key, want string) {
+	t.Helper()
+	ch, err := c.Chunk(name, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	got, exist := ch.GetRaw([]byte(key))
+	if want == "" {
+		if exist {
+			t.Fatalf("key %q: expected missing, got %q", key, got)
+		}
+		return
+	}
+	if !exist || string(got) != want {
+		t.Fatalf("key %q: want=%q got=%q exist=%v", key, want, got, exist)
+	}
+}
+
+func TestChunkMergeDisjointKeys(t *testing.T) {
+	const name = "chunk_merge_disjoint"
+	c := newFreeCache()
+
+	seed, err := c.Chunk(name, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	seed.SetRaw([]byte("shared"), []byte("0"))
+	seed.SetRaw([]byte("to-delete"), []byte("0"))
+	if err := seed.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	a, b := openPair(t, c, name, cache.WithMerge(nil))
+
+	a.SetRaw([]byte("a"), []byte("A"))
+	a.Del([]byte("to-delete"))
+	if err := a.SaveChanges(); err != nil {
+		t.Fatalf("A.SaveChanges(): %v", err)
+	}
+
+	b.SetRaw([]byte("b"), []byte("B"))
+	if err := b.SaveChanges(); err != nil {
+		t.Fatalf("B.SaveChanges() with merge: %v", err)
+	}
+
+	// B після злиття бачить зміни A
+	if got, _ := b.GetRaw([]byte("a")); string(got) != "A" {
+		t.Fatalf("B after merge: expected key a=A, got %q", got)
+	}
+
+	expectRaw(t, c, name, "a", "A")
+	expectRaw(t, c, name, "b", "B")
+	expectRaw(t, c, name, "shared", "0")
+	expectRaw(t, c, name, "to-delete", "")
+}
+
+func TestChunkMergeSameKeyConflict(t *testing.T) {
+	const name = "chunk_merge_conflict"
+	c := newFreeCache()
+
+	a, b := openPair(t, c, name, cache.WithMerge(nil))
+	a.SetRaw([]byte("k"), []byte("A"))
+	if err := a.SaveChanges(); err != nil {
+		t.Fatalf("A.SaveChanges(): %v", err)
+	}
+
+	b.SetRaw([]byte("k"), []byte("B"))
+	if err := b.SaveChanges(); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("B.SaveChanges(): expected ErrChunkConflict, got %v", err)
+	}
+
+	// однакова зміна з обох боків — не конфлікт
+	a, b = openPair(t, c, name, cache.WithMerge(nil))
+	a.SetRaw([]byte("k"), []byte("same"))
+	b.SetRaw([]byte("k"), []byte("same"))
+	if err := a.SaveChanges(); err != nil {
+		t.Fatalf("A.SaveChanges(): %v", err)
+	}
+	if err := b.SaveChanges(); err != nil {
+		t.Fatalf("B.SaveChanges() identical change: %v", err)
+	}
+	expectRaw(t, c, name, "k", "same")
+}
+
+func TestChunkMergeResolver(t *testing.T) {
+	const name = "chunk_merge_resolver"
+	c := newFreeCache()
+
+	var seen cache.MergeConflict
+	resolver := func(mc cache.MergeConflict) ([]byte, bool, error) {
+		seen = mc
+		return append(append([]byte{}, mc.Theirs...), mc.Ours...), true, nil
+	}
+
+	a, b := openPair(t, c, name, cache.WithMerge(resolver))
+	a.SetRaw([]byte("k"), []byte("A"))
+	if err := a.SaveChanges(); err != nil {
+		t.Fatalf("A.SaveChanges(): %v", err)
+	}
+	b.SetRaw([]byte("k"), []byte("B"))
+	if err := b.SaveChanges(); err != nil {
+		t.Fatalf("B.SaveChanges(): %v", err)
+	}
+
+	if seen.BaseExist || !seen.OursExist || !seen.TheirsExist ||
+		!bytes.Equal(seen.Ours, []byte("B")) || !bytes.Equal(seen.Theirs, []byte("A")) {
+		t.Fatalf("unexpected MergeConflict: %+v", seen)
+	}
+	expectRaw(t, c, name, "k", "AB")
+}
+
+func TestUpdateChunkWithMerge(t *testing.T) {
+	const name = "chunk_update_merge"
+	c := newFreeCache()
+
+	// кожен writer пише свій ключ — із merge жодна спроба не має вичерпатися
+	runConcurrently(8, func(i int) {
+		err := c.UpdateChunk(name, 60, func(ch *cache.Chunk) error {
+			ch.SetRaw([]byte{'k', byte('0' + i)}, []byte{byte('0' + i)})
+			return nil
+		}, cache.WithChunkOptions(cache.WithMerge(nil)), cache.WithBackoff(time.Millisecond, 5*time.Millisecond))
+		if err != nil {
+			t.Errorf("UpdateChunk(): %v", err)
+		}
+	})
+
+	for i := 0; i < 8; i++ {
+		expectRaw(t, c, name, "k"+string(rune('0'+i)), string(rune('0'+i)))
+	}
+}
@@ -0,0 +1,104 @@
+package cache
+
+import "errors"
+
+// ErrChunkOptions повертається Cache.Chunk для несумісного набору ChunkOption.
+var ErrChunkOptions = errors.New("несумісні опції чанку")
+
+// ChunkOption налаштовує Chunk під час відкриття (див. Cache.Chunk).
+type ChunkOption func(*chunkOptions)
+
+type chunkOptions struct {
+	merge    bool
+	resolver MergeResolver
+
+	deltaLog     bool
+	compactEvery int
+
+	pages int
+
+	lazy bool
+
+	codec Codec
+}
+
+// WithMerge вмикає злиття на рівні ключів при ErrChunkConflict у SaveChanges.
+//
+// Замість помилки SaveChanges перечитує поточний ChunkRaw, переносить на нього лише ключі,
+// змінені цим снапшотом, і комітить результат. Конфліктом вважається лише ключ,
+// який змінили обидва writer-и (і по-різному); такий ключ передається в resolver.
+// Якщо resolver == nil, SaveChanges у цьому випадку повертає ErrChunkConflict.
+func WithMerge(resolver MergeResolver) ChunkOption {
+	return func(o *chunkOptions) {
+		o.merge = true
+		o.resolver = resolver
+	}
+}
+
+// WithDeltaLog зберігає чанк як базовий снапшот плюс журнал змін по ключах.
+//
+// Кожен SaveChanges пише лише запис журналу з ключами, зміненими снапшотом (окремий ключ драйвера
+// на кожну версію), тож ціна коміту пропорційна розміру змін, а не розміру чанку.
+// Після compactEvery записів журналу (за замовчуванням 64) або коли базовому снапшоту минає
+// половина TTL чанку, writer стискає журнал у новий базовий снапшот.
+//
+// Чанк, збережений у звичайному форматі, відкривається з WithDeltaLog без міграції.
+// Зворотне не працює: чанк з журналом треба завжди відкривати з WithDeltaLog.
+//
+// TTL: базовий снапшот оновлюється не рідше ніж раз на половину TTL, а versionKey і записи журналу
+// пишуться з TTL, що не перевищує залишку TTL бази. Тож після останнього коміту дані живуть
+// щонайменше expiriesSecond/2 секунд і зникають цілком. Якщо базу витіснено раніше,
+// Chunk() повертає ErrChunkCorrupted.
+func WithDeltaLog(compactEvery int) ChunkOption {
+	return func(o *chunkOptions) {
+		o.deltaLog = true
+		o.compactEvery = compactEvery
+		if o.compactEvery <= 0 {
+			o.compactEvery = 64
+		}
+	}
+}
+
+// WithPages шардує Data чанку на pages сторінок за хешем ключа; кожна сторінка зберігається
+// окремим ключем драйвера, тож розмір одного запису не впирається в ліміт драйвера
+// (для freecache — 1/1024 розміру кешу).
+//
+// Відкриття чанку читає лише versionKey і маніфест сторінок; сторінка завантажується
+// при першому зверненні до її ключа, а SaveChanges переписує лише змінені сторінки
+// разом з маніфестом і versionKey (атомарно, як звичайний коміт). Якщо сторінку переписали
+// після відкриття снапшота, її завантаження не вдається (див. Chunk.Err).
+//
+// Кількість сторінок — частина формату: чанк треба відкривати з тим самим pages.
+// Чанк у звичайному форматі розкладається по сторінках першим SaveChanges.
+// WithPages не поєднується з WithDeltaLog і WithMerge.
+func WithPages(pages int) ChunkOption {
+	return func(o *chunkOptions) {
+		o.pages = max(pages, 0)
+	}
+}
+
+// WithLazyLoad відкриває чанк без декодування payload: Cache.Chunk читає з нього лише Version.
+// Перший Get/GetRaw один раз проходить msgpack і будує індекс зміщень значень (без копіювання
+// самих значень), далі кожен ключ декодується окремо при зверненні. Зміни снапшота тримаються
+// поверх payload; SaveChanges, Clear і злиття (WithMerge) декодують payload повністю.
+//
+// Підходить для read-mostly чанків, з яких читають кілька ключів.
+// Помилку декодування payload при лінивому читанні повертають Get і OnSet; GetRaw повідомляє промах,
+// а помилку видно з Chunk.Err.
+// WithLazyLoad не поєднується з WithDeltaLog, WithPages (сторінки і так завантажуються ліниво)
+// і з кодеками, відмінними від CodecMsgpack.
+func WithLazyLoad() ChunkOption {
+	return func(o *chunkOptions) {
+		o.lazy = true
+	}
+}
+
+// WithCodec задає кодек значень Set/Get/OnSet і payload чанку (за замовчуванням CodecMsgpack).
+//
+// Кодек — частина формату: ідентифікатор кодека зберігається в payload, і чанк, записаний
+// іншим кодеком, не відкривається (ErrChunkCodec). Значення GetRaw/SetRaw кодек не змінює.
+func WithCodec(codec Codec) ChunkOption {
+	return func(o *chunkOptions) {
+		o.codec = codec
+	}
+}
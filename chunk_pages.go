@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// pageMeta — запис маніфесту про одну сторінку: версія коміту, який її востаннє записав
+// (0 — сторінку ще не записували), і unix-час цього запису.
+type pageMeta struct {
+	version   uint64
+	writtenAt int64
+}
+
+// chunkPages — RAM-стан шардованого чанку (WithPages).
+type chunkPages struct {
+	// meta — маніфест на момент завантаження снапшота (або останнього коміту).
+	meta []pageMeta
+	// data — дані сторінок; nil — сторінка ще не завантажена.
+	data []map[string][]byte
+	// dirty — сторінки, змінені снапшотом.
+	dirty []bool
+	// pending — маніфест, який пише поточний коміт.
+	pending []pageMeta
+	// legacy — снапшот завантажено з payload звичайного формату; після коміту payload видаляється.
+	legacy bool
+}
+
+// getChunkPagesKey — ключ маніфесту сторінок WithPages.
+func getChunkPagesKey(name string) []byte {
+	return chunkKey(name, chunkPartPages)
+}
+
+// getChunkPageKey — ключ сторінки i.
+func getChunkPageKey(name string, i int) []byte {
+	return chunkKeyN(name, chunkPartPage, uint64(i))
+}
+
+// pageKeys повертає ключі сторінок чанку name за його маніфестом. Без маніфесту (або з пошкодженим)
+// сторінок не знайти, і результат порожній.
+func (ch *Cache) pageKeys(ctx context.Context, name string) ([][]byte, error) {
+	pagesKey := getChunkPagesKey(name)
+	vals, err := ch.getMany(ctx, [][]byte{pagesKey})
+	if err != nil {
+		return nil, err
+	}
+	manifest, ok := vals[string(pagesKey)]
+	if !ok {
+		return nil, nil
+	}
+	_, meta, err := decodePageManifest(manifest)
+	if err != nil {
+		return nil, nil
+	}
+	keys := make([][]byte, len(meta))
+	for i := range meta {
+		keys[i] = getChunkPageKey(name, i)
+	}
+	return keys, nil
+}
+
+// pageIndex визначає сторінку ключа за fnv-хешем.
+func pageIndex(key string, pages int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(pages))
+}
+
+// encodePageManifest пакує маніфест: версія чанку, далі по 16 байт (версія, час запису) на сторінку.
+func encodePageManifest(ver uint64, meta []pageMeta) []byte {
+	b := binary.LittleEndian.AppendUint64(make([]byte, 0, 8+16*len(meta)), ver)
+	for _, m := range meta {
+		b = binary.LittleEndian.AppendUint64(b, m.version)
+		b = binary.LittleEndian.AppendUint64(b, uint64(m.writtenAt))
+	}
+	return b
+}
+
+// decodePageManifest розбирає маніфест, записаний encodePageManifest.
+func decodePageManifest(b []byte) (uint64, []pageMeta, error) {
+	if len(b) < 8 || (len(b)-8)%16 != 0 {
+		return 0, nil, ErrChunkCorrupted
+	}
+	ver := binary.LittleEndian.Uint64(b)
+	meta := make([]pageMeta, (len(b)-8)/16)
+	for i := range meta {
+		off := 8 + 16*i
+		meta[i] = pageMeta{
+			version:   binary.LittleEndian.Uint64(b[off:]),
+			writtenAt: int64(binary.LittleEndian.Uint64(b[off+8:])),
+		}
+	}
+	return ver, meta, nil
+}
+
+// readPagedChunk читає versionKey і маніфест сторінок; самі сторінки завантажуються на вимогу.
+// Якщо маніфесту ще немає, а payload звичайного формату є — він розкладається по сторінках
+// і переписується першим комітом.
+func (ch *Chunk) readPagedChunk(ctx context.Context) (chunkSnapshot, error) {
+	versionKey, pagesKey, payloadKey := getChunkVersionKey(ch.name), getChunkPagesKey(ch.name), getChunkKey(ch.name)
+	vals, err := ch.ch.getMany(ctx, [][]byte{versionKey, pagesKey, payloadKey, getChunkBaseKey(ch.name)})
+	if err != nil {
+		return chunkSnapshot{}, err
+	}
+	if _, ok := vals[string(getChunkBaseKey(ch.name))]; ok {
+		return chunkSnapshot{}, ErrChunkLayout
+	}
+
+	var snap chunkSnapshot
+	if snap.verKey, snap.verKeyExist, err = decodeVersionKey(vals, versionKey); err != nil {
+		return chunkSnapshot{}, err
+	}
+
+	if manifest, ok := vals[string(pagesKey)]; ok {
+		ver, meta, err := decodePageManifest(manifest)
+		if err != nil {
+			return chunkSnapshot{}, err
+		}
+		if len(meta) != ch.opts.pages {
+			return chunkSnapshot{}, ErrChunkLayout
+		}
+		snap.raw = ChunkRaw{Version: ver, Data: make(map[string][]byte)}
+		snap.pages = meta
+		return snap, nil
+	}
+
+	payload, payloadExist := vals[string(payloadKey)]
+	if snap.raw, err = decodeChunkRaw(payload, payloadExist, ch.opts.codec); err != nil {
+		return chunkSnapshot{}, err
+	}
+	snap.pages = make([]pageMeta, ch.opts.pages)
+	snap.legacyPayload = payloadExist
+	snap.fresh = !payloadExist
+	if snap.fresh && snap.verKeyExist {
+		// чанк уже відкрито, але перший коміт ще не записав маніфест
+		snap.raw.Version = snap.verKey
+	}
+	return snap, nil
+}
+
+// resetPages ініціалізує RAM-стан сторінок зі снапшота. Викликається під mu.
+// Сторінки, яких ще не записували, одразу вважаються завантаженими (порожніми).
+func (ch *Chunk) resetPages(snap chunkSnapshot) {
+	n := len(snap.pages)
+	ch.pages = chunkPages{
+		meta:   snap.pages,
+		data:   make([]map[string][]byte, n),
+		dirty:  make([]bool, n),
+		legacy: snap.legacyPayload,
+	}
+	for i, m := range snap.pages {
+		if m.version == 0 {
+			ch.pages.data[i] = make(map[string][]byte)
+		}
+	}
+	for k, v := range snap.raw.Data {
+		i := pageIndex(k, n)
+		ch.pages.data[i][k] = v
+		ch.pages.dirty[i] = true
+	}
+	ch.err = nil
+}
+
+// loadPage завантажує сторінку i, якщо її ще немає в RAM. Викликається під mu.
+//
+// Помилка завантаження стає "липкою" (див. Err): сторінка, переписана після завантаження
+// снапшота, дає ErrChunkConflict, а витіснена чи прострочена — ErrChunkCorrupted.
+func (ch *Chunk) loadPage(ctx context.Context, i int) {
+	if ch.pages.data[i] != nil || ch.err != nil {
+		return
+	}
+	raw, exist, err := ch.ch.get(ctx, getChunkPageKey(ch.name, i))
+	if err != nil {
+		ch.err = err
+		return
+	}
+	if !exist {
+		ch.err = ErrChunkCorrupted
+		return
+	}
+	page, err := decodeChunkRaw(raw, true, ch.opts.codec)
+	if err != nil {
+		ch.err = err
+		return
+	}
+	if page.Version != ch.pages.meta[i].version {
+		ch.err = ErrChunkConflict
+		return
+	}
+	ch.pages.data[i] = page.Data
+}
+
+// pageData повертає map сторінки ключа, завантажуючи її за потреби.
+// Повертає nil, якщо сторінку не вдалося завантажити. Викликається під mu.
+func (ch *Chunk) pageData(key string, write bool) map[string][]byte {
+	i := pageIndex(key, len(ch.pages.data))
+	ch.loadPage(context.Background(), i)
+	if write && ch.pages.data[i] != nil {
+		ch.pages.dirty[i] = true
+	}
+	return ch.pages.data[i]
+}
+
+// clearPages робить усі сторінки порожніми і зміненими. Викликається під mu.
+func (ch *Chunk) clearPages() {
+	for i := range ch.pages.data {
+		ch.pages.data[i] = make(map[string][]byte)
+		ch.pages.dirty[i] = true
+	}
+}
+
+// pagedCommitItems формує записи змінених сторінок і нового маніфесту. Викликається під mu.
+//
+// Якщо в чанку є TTL, разом зі зміненими переписуються сторінки, записані понад половину TTL тому:
+// сторінки живуть окремими ключами, і без цього давно не змінювані сторінки зникли б раніше за чанк.
+func (ch *Chunk) pagedCommitItems(ctx context.Context, newVer uint64) (map[string][]byte, error) {
+	now := ch.ch.now().Unix()
+	if ch.expiriesSecond > 0 {
+		for i, m := range ch.pages.meta {
+			if m.version > 0 && !ch.pages.dirty[i] && now-m.writtenAt >= int64(ch.expiriesSecond)/2 {
+				ch.loadPage(ctx, i)
+				ch.pages.dirty[i] = true
+			}
+		}
+	}
+	if ch.err != nil {
+		return nil, ch.err
+	}
+
+	meta := append([]pageMeta(nil), ch.pages.meta...)
+	items := make(map[string][]byte)
+	for i, dirty := range ch.pages.dirty {
+		if !dirty {
+			continue
+		}
+		payload, err := encodeChunkRaw(ChunkRaw{Version: newVer, Data: ch.pages.data[i]}, ch.opts.codec)
+		if err != nil {
+			return nil, err
+		}
+		items[string(getChunkPageKey(ch.name, i))] = payload
+		meta[i] = pageMeta{version: newVer, writtenAt: now}
+	}
+	items[string(getChunkPagesKey(ch.name))] = encodePageManifest(newVer, meta)
+	ch.pages.pending = meta
+	return items, nil
+}
+
+// afterPagedCommit фіксує новий маніфест після успішного коміту. Викликається під mu.
+func (ch *Chunk) afterPagedCommit(ctx context.Context) {
+	ch.pages.meta = ch.pages.pending
+	ch.pages.pending = nil
+	clear(ch.pages.dirty)
+	if ch.pages.legacy {
+		// payload старого формату вже розкладено по сторінках; не вдалося видалити — зникне за TTL
+		_ = ch.ch.del(ctx, getChunkKey(ch.name))
+		ch.pages.legacy = false
+	}
+}
@@ -0,0 +1,205 @@
+package cache_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/drivers"
+)
+
+func openPaged(t *testing.T, c *cache.Cache, name string, pages int) *cache.Chunk {
+	t.Helper()
+	ch, err := c.Chunk(name, 60, cache.WithPages(pages))
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	return ch
+}
+
+func TestChunkPagesLargeChunk(t *testing.T) {
+	testChunkPagesLargeChunk(t, newFreeCache())
+	testChunkPagesLargeChunk(t, newBadgerCache(t))
+}
+
+// testChunkPagesLargeChunk: чанк у кілька разів більший за ліміт одного запису freecache.
+func testChunkPagesLargeChunk(t *testing.T, c *cache.Cache) {
+	const (
+		name = "chunk_pages_large"
+		keys = 2000
+	)
+	val := []byte(strings.Repeat("x", 50))
+
+	ch := openPaged(t, c, name, 64)
+	for i := 0; i < keys; i++ {
+		ch.SetRaw([]byte(fmt.Sprintf("key-%d", i)), val)
+	}
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	ch = openPaged(t, c, name, 64)
+	for i := 0; i < keys; i++ {
+		got, exist := ch.GetRaw([]byte(fmt.Sprintf("key-%d", i)))
+		if !exist || string(got) != string(val) {
+			t.Fatalf("key-%d: exist=%v got=%q", i, exist, got)
+		}
+	}
+	if err := ch.Err(); err != nil {
+		t.Fatalf("Err(): %v", err)
+	}
+}
+
+func TestChunkPagesLazyLoad(t *testing.T) {
+	const name = "chunk_pages_lazy"
+	dr := &countingDriver{CacheDriver: newFreeCacheDriver()}
+	c := cache.NewCache(dr)
+
+	ch := openPaged(t, c, name, 16)
+	for i := 0; i < 200; i++ {
+		ch.SetRaw([]byte(fmt.Sprintf("key-%d", i)), []byte("v"))
+	}
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	ch = openPaged(t, c, name, 16)
+	before := dr.gets.Load()
+	if got, _ := ch.GetRaw([]byte("key-42")); string(got) != "v" {
+		t.Fatalf("key-42: expected v, got %q", got)
+	}
+	if got, _ := ch.GetRaw([]byte("key-42")); string(got) != "v" {
+		t.Fatalf("key-42 (cached page): expected v, got %q", got)
+	}
+	if reads := dr.gets.Load() - before; reads != 1 {
+		t.Fatalf("expected exactly one page read, got %d", reads)
+	}
+}
+
+func TestChunkPagesConflict(t *testing.T) {
+	const name = "chunk_pages_conflict"
+	c := newFreeCache()
+
+	seed := openPaged(t, c, name, 8)
+	seed.SetRaw([]byte("k"), []byte("0"))
+	if err := seed.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	a := openPaged(t, c, name, 8)
+	b := openPaged(t, c, name, 8)
+	b.SetRaw([]byte("k"), []byte("B"))
+	if err := b.SaveChanges(); err != nil {
+		t.Fatalf("B.SaveChanges(): %v", err)
+	}
+
+	// сторінку "k" переписали після відкриття A: її не можна змішувати зі снапшотом A
+	if _, exist := a.GetRaw([]byte("k")); exist {
+		t.Fatal("GetRaw(): A must not see a page written after its snapshot")
+	}
+	if err := a.Err(); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("Err(): expected ErrChunkConflict, got %v", err)
+	}
+
+	// помилка сторінки — не промах: OnSet не викликає завантажувач, а записи не приймаються
+	var v string
+	if err := a.OnSet([]byte("k"), &v, func() (any, error) {
+		t.Fatalf("OnSet(): loader called for a key on a page that failed to load")
+		return nil, nil
+	}); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("OnSet(): expected ErrChunkConflict, got %v", err)
+	}
+	if err := a.Set([]byte("k"), "A"); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("Set(): expected ErrChunkConflict, got %v", err)
+	}
+	a.SetRaw([]byte("other"), []byte("A"))
+	if err := a.SaveChanges(); !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("SaveChanges(): expected ErrChunkConflict, got %v", err)
+	}
+}
+
+func TestChunkPagesLayout(t *testing.T) {
+	const name = "chunk_pages_layout"
+	c := newFreeCache()
+
+	legacy, err := c.Chunk(name, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	legacy.SetRaw([]byte("old"), []byte("1"))
+	if err := legacy.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() legacy: %v", err)
+	}
+
+	// звичайний чанк розкладається по сторінках першим комітом
+	ch := openPaged(t, c, name, 8)
+	ch.SetRaw([]byte("new"), []byte("2"))
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() paged: %v", err)
+	}
+	ch = openPaged(t, c, name, 8)
+	if got, _ := ch.GetRaw([]byte("old")); string(got) != "1" {
+		t.Fatalf("old after migration: expected 1, got %q", got)
+	}
+	if got, _ := ch.GetRaw([]byte("new")); string(got) != "2" {
+		t.Fatalf("new after migration: expected 2, got %q", got)
+	}
+
+	if _, err := c.Chunk(name, 60, cache.WithPages(16)); !errors.Is(err, cache.ErrChunkLayout) {
+		t.Fatalf("other page count: expected ErrChunkLayout, got %v", err)
+	}
+	if _, err := c.Chunk(name, 60); !errors.Is(err, cache.ErrChunkLayout) {
+		t.Fatalf("without WithPages: expected ErrChunkLayout, got %v", err)
+	}
+	if _, err := c.Chunk(name, 60, cache.WithPages(8), cache.WithMerge(nil)); !errors.Is(err, cache.ErrChunkOptions) {
+		t.Fatalf("WithPages+WithMerge: expected ErrChunkOptions, got %v", err)
+	}
+}
+
+// DeleteChunk видаляє сторінки з маніфесту: без TTL вони інакше лишилися б у сховищі назавжди.
+func TestChunkPagesDeleteChunk(t *testing.T) {
+	const name = "chunk_pages_delete"
+	dr, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	c := cache.NewCache(dr)
+	defer c.Close()
+
+	ch, err := c.Chunk(name, 0, cache.WithPages(4))
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		ch.SetRaw([]byte(fmt.Sprintf("key-%d", i)), []byte("v"))
+	}
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	pageExists := func(i int) bool {
+		t.Helper()
+		_, exist, err := dr.Get(binary.BigEndian.AppendUint64(chunkStorageKey(name, 'g'), uint64(i)))
+		if err != nil {
+			t.Fatalf("Get(page %d): %v", i, err)
+		}
+		return exist
+	}
+	for i := 0; i < 4; i++ {
+		if !pageExists(i) {
+			t.Fatalf("page %d was not written", i)
+		}
+	}
+
+	if err := c.DeleteChunk(name); err != nil {
+		t.Fatalf("DeleteChunk(): %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if pageExists(i) {
+			t.Fatalf("page %d left after DeleteChunk", i)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package cache_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"runtime/debug"
+	"strconv"
 	"testing"
 	"time"
 
@@ -21,6 +23,17 @@ func TestFreeCacheDriverChunk(t *testing.T) {
 	testLogicChunk(t, cache.NewCache(drivers.NewFreeCacheDriver(fc)))
 }
 
+func TestBadgerDBDriverChunk(t *testing.T) {
+	dr, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	c := cache.NewCache(dr)
+	defer c.Close()
+
+	testLogicChunk(t, c)
+}
+
 func testLogicChunk(t *testing.T, ch *cache.Cache) {
 	testGetSetChunk(t, ch)
 	testGetAndDelRawChunk(t, ch)
@@ -29,6 +42,20 @@ func testLogicChunk(t *testing.T, ch *cache.Cache) {
 	testCopySemanticsChunk(t, ch)
 	testChunkConflictCAS(t, ch)
 	testChunkTTLExpires(t, ch)
+	testChunkContext(t, ch)
+	testChunkConcurrentSaveChanges(t, ch, "chunk_stress")
+	testChunkConcurrentSaveChanges(t, ch, "chunk_stress_delta", cache.WithDeltaLog(4))
+	testChunkConcurrentSaveChanges(t, ch, "chunk_stress_paged", cache.WithPages(4))
+	testChunkConcurrentSaveChanges(t, ch, "chunk_stress_lazy", cache.WithLazyLoad())
+}
+
+func TestChunkConcurrentSaveChangesFallback(t *testing.T) {
+	testChunkConcurrentSaveChanges(t, cache.NewCache(plainDriver{newFreeCacheDriver()}), "chunk_stress")
+}
+
+// Повільні окремі Get versionKey і payload перетинаються з комітами інших горутин.
+func TestChunkConcurrentSaveChangesSlowReads(t *testing.T) {
+	testChunkConcurrentSaveChanges(t, cache.NewCache(slowGetDriver{newFreeCacheDriver().(*drivers.FreeCacheDriver)}), "chunk_stress")
 }
 
 // ------------------------------------------------------------
@@ -328,3 +355,110 @@ func testChunkTTLExpires(t *testing.T, c *cache.Cache) {
 		t.Fatalf("expected value to expire, got=%+v", got)
 	}
 }
+
+func testChunkContext(t *testing.T, c *cache.Cache) {
+	const chunkName = "chunk_ctx"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.ChunkCtx(ctx, chunkName, 60); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ChunkCtx(): expected context.Canceled, got: %v", err)
+	}
+
+	ch, err := c.Chunk(chunkName, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	ch.SetRaw([]byte("k"), []byte("v"))
+
+	if err := ch.SaveChangesCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SaveChangesCtx(): expected context.Canceled, got: %v", err)
+	}
+
+	// після невдалого коміту зміни залишаються в RAM і комітяться з живим ctx
+	if err := ch.SaveChangesCtx(context.Background()); err != nil {
+		t.Fatalf("SaveChangesCtx(): %v", err)
+	}
+}
+
+// testChunkConcurrentSaveChanges: N горутин інкрементують один ключ чанку через
+// reload -> set -> SaveChanges з повтором на ErrChunkConflict; жодне оновлення не має загубитися.
+func testChunkConcurrentSaveChanges(t *testing.T, c *cache.Cache, chunkName string, opts ...cache.ChunkOption) {
+	const (
+		workers = 8
+		perWork = 25
+	)
+	key := []byte("counter")
+
+	runConcurrently(workers, func(int) {
+		for done := 0; done < perWork; {
+			ch, err := c.Chunk(chunkName, 60, opts...)
+			if errors.Is(err, cache.ErrChunkConflict) {
+				continue
+			}
+			if err != nil {
+				t.Errorf("Chunk(): %v", err)
+				return
+			}
+
+			// WithPages: сторінку могли переписати після відкриття снапшота — це теж конфлікт
+			var n int
+			if _, err := ch.Get(key, &n); errors.Is(err, cache.ErrChunkConflict) {
+				continue
+			} else if err != nil {
+				t.Errorf("Get(): %v", err)
+				return
+			}
+			if err := ch.Set(key, n+1); err != nil {
+				t.Errorf("Set(): %v", err)
+				return
+			}
+
+			err = ch.SaveChanges()
+			if errors.Is(err, cache.ErrChunkConflict) {
+				continue
+			}
+			if err != nil {
+				t.Errorf("SaveChanges(): %v", err)
+				return
+			}
+			done++
+		}
+	})
+
+	ch, err := c.Chunk(chunkName, 60, opts...)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	var n int
+	if _, err := ch.Get(key, &n); err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if n != workers*perWork {
+		t.Fatalf("expected counter=%d, got %d (lost updates)", workers*perWork, n)
+	}
+}
+
+// slowGetDriver додає затримку до читань, зберігаючи атомарні операції FreeCacheDriver.
+type slowGetDriver struct {
+	*drivers.FreeCacheDriver
+}
+
+func (d slowGetDriver) GetCtx(ctx context.Context, key []byte) ([]byte, bool, error) {
+	time.Sleep(200 * time.Microsecond)
+	return d.FreeCacheDriver.GetCtx(ctx, key)
+}
+
+// Конкурентні відкриття нового чанку ідемпотентні: програш SetNX versionKey не є конфліктом.
+func TestChunkConcurrentOpen(t *testing.T) {
+	c := cache.NewCache(slowGetDriver{newFreeCacheDriver().(*drivers.FreeCacheDriver)})
+	for i := 0; i < 10; i++ {
+		name := "chunk_open_" + strconv.Itoa(i)
+		runConcurrently(40, func(int) {
+			if _, err := c.Chunk(name, 60); err != nil {
+				t.Errorf("Chunk(%s): %v", name, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrChunkCodec означає, що payload чанку записано іншим кодеком, ніж задано WithCodec
+	// (або payload не декодується цим кодеком).
+	ErrChunkCodec = errors.New("payload чанку записано іншим кодеком")
+
+	// ErrNotProtoMessage повертає CodecProtobuf для значень, які не реалізують proto.Message.
+	ErrNotProtoMessage = errors.New("значення не є proto.Message")
+)
+
+// Codec кодує значення Chunk.Set/Get/OnSet і payload ChunkRaw чанку (див. WithCodec).
+//
+// Name зберігається в payload (ChunkRaw.Codec), тож чанк, відкритий з іншим кодеком,
+// дає ErrChunkCodec замість сміття. Payload кодується через Marshal(ChunkRaw);
+// записи журналу WithDeltaLog — службовий формат і завжди кодуються msgpack.
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// chunkRawCodec — кодек зі своїм форматом payload (protobuf не вміє кодувати довільні структури).
+type chunkRawCodec interface {
+	marshalChunkRaw(raw ChunkRaw) ([]byte, error)
+	unmarshalChunkRaw(data []byte) (ChunkRaw, error)
+}
+
+// Вбудовані кодеки.
+var (
+	// CodecMsgpack — кодек за замовчуванням. Його payload не містить ідентифікатора кодека,
+	// тож збігається з форматом чанків до появи кодеків.
+	CodecMsgpack Codec = msgpackCodec{}
+	// CodecJSON кодує значення через encoding/json; payload — JSON-обʼєкт
+	// {"Version": ..., "Data": {key: base64}, "Codec": "json"}, зручний для інших мов.
+	CodecJSON Codec = jsonCodec{}
+	// CodecGob кодує значення через encoding/gob.
+	CodecGob Codec = gobCodec{}
+	// CodecProtobuf кодує значення, що реалізують proto.Message; payload — protobuf-повідомлення
+	// (1: version, 2: repeated {1: key, 2: value}, 3: codec).
+	CodecProtobuf Codec = protobufCodec{}
+)
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) { return msgpack.Marshal(v) }
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// Номери полів payload CodecProtobuf.
+const (
+	pbFieldVersion = 1
+	pbFieldEntry   = 2
+	pbFieldCodec   = 3
+
+	pbFieldEntryKey   = 1
+	pbFieldEntryValue = 2
+)
+
+func (protobufCodec) marshalChunkRaw(raw ChunkRaw) ([]byte, error) {
+	var b []byte
+	b = protowire.AppendTag(b, pbFieldVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, raw.Version)
+	for key, val := range raw.Data {
+		var entry []byte
+		entry = protowire.AppendTag(entry, pbFieldEntryKey, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, pbFieldEntryValue, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, val)
+
+		b = protowire.AppendTag(b, pbFieldEntry, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	b = protowire.AppendTag(b, pbFieldCodec, protowire.BytesType)
+	return protowire.AppendString(b, raw.Codec), nil
+}
+
+func (protobufCodec) unmarshalChunkRaw(data []byte) (ChunkRaw, error) {
+	raw := ChunkRaw{Data: make(map[string][]byte)}
+	err := walkProtoFields(data, func(num protowire.Number, typ protowire.Type, field []byte) error {
+		switch {
+		case num == pbFieldVersion && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(field)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			raw.Version = v
+		case num == pbFieldCodec && typ == protowire.BytesType:
+			raw.Codec = string(field)
+		case num == pbFieldEntry && typ == protowire.BytesType:
+			var (
+				key string
+				val = []byte{}
+			)
+			err := walkProtoFields(field, func(num protowire.Number, typ protowire.Type, field []byte) error {
+				switch {
+				case num == pbFieldEntryKey && typ == protowire.BytesType:
+					key = string(field)
+				case num == pbFieldEntryValue && typ == protowire.BytesType:
+					val = bytes.Clone(field)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			raw.Data[key] = val
+		}
+		return nil
+	})
+	return raw, err
+}
+
+// walkProtoFields обходить поля protobuf-повідомлення. Для VarintType fn отримує закодований varint,
+// для BytesType — вміст поля; поля інших типів пропускаються.
+func walkProtoFields(b []byte, fn func(num protowire.Number, typ protowire.Type, field []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var field []byte
+		switch typ {
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(b)
+			if n >= 0 {
+				field = b[:n]
+			}
+		case protowire.BytesType:
+			field, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if field != nil || typ == protowire.BytesType {
+			if err := fn(num, typ, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// payloadCodecName — ідентифікатор кодека, який записується в payload ("" — msgpack).
+func payloadCodecName(codec Codec) string {
+	if name := codec.Name(); name != CodecMsgpack.Name() {
+		return name
+	}
+	return ""
+}
+
+// encodeChunkRaw серіалізує ChunkRaw кодеком codec і записує в нього ідентифікатор кодека.
+func encodeChunkRaw(chunkData ChunkRaw, codec Codec) ([]byte, error) {
+	chunkData.Codec = payloadCodecName(codec)
+	if rc, ok := codec.(chunkRawCodec); ok {
+		return rc.marshalChunkRaw(chunkData)
+	}
+	return codec.Marshal(chunkData)
+}
+
+// decodeChunkRaw декодує payload кодеком codec або повертає порожній ChunkRaw, якщо payload відсутній.
+// Payload іншого кодека дає ErrChunkCodec. Повернутий ChunkRaw завжди має не-nil Data.
+func decodeChunkRaw(rawData []byte, exist bool, codec Codec) (ChunkRaw, error) {
+	var chunkData ChunkRaw
+	if exist {
+		var err error
+		if rc, ok := codec.(chunkRawCodec); ok {
+			chunkData, err = rc.unmarshalChunkRaw(rawData)
+		} else {
+			err = codec.Unmarshal(rawData, &chunkData)
+		}
+		if err != nil {
+			return ChunkRaw{}, fmt.Errorf("%w: %w", ErrChunkCodec, err)
+		}
+		if chunkData.Codec != payloadCodecName(codec) {
+			return ChunkRaw{}, ErrChunkCodec
+		}
+	}
+
+	if chunkData.Data == nil {
+		chunkData.Data = make(map[string][]byte)
+	}
+
+	return chunkData, nil
+}
@@ -0,0 +1,107 @@
+package cache_test
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/v-grabko1999/cache"
+)
+
+type codecUser struct {
+	Name string
+	Age  int
+}
+
+func TestChunkCodecsFreeCache(t *testing.T) {
+	testChunkCodecs(t, newFreeCache())
+}
+
+func TestChunkCodecsBadgerDB(t *testing.T) {
+	testChunkCodecs(t, newBadgerCache(t))
+}
+
+func testChunkCodecs(t *testing.T, c *cache.Cache) {
+	for _, codec := range []cache.Codec{cache.CodecMsgpack, cache.CodecJSON, cache.CodecGob} {
+		for mode, opts := range map[string][]cache.ChunkOption{
+			"plain": nil,
+			"delta": {cache.WithDeltaLog(2)},
+			"paged": {cache.WithPages(4)},
+		} {
+			name := "codec_" + codec.Name() + "_" + mode
+			opts = append(opts, cache.WithCodec(codec))
+
+			chunk, err := c.Chunk(name, 60, opts...)
+			if err != nil {
+				t.Fatalf("%s: Chunk(): %v", name, err)
+			}
+			if err := chunk.Set([]byte("u"), codecUser{Name: "Ada", Age: 36}); err != nil {
+				t.Fatalf("%s: Set(): %v", name, err)
+			}
+			if err := chunk.SaveChanges(); err != nil {
+				t.Fatalf("%s: SaveChanges(): %v", name, err)
+			}
+
+			reopened, err := c.Chunk(name, 60, opts...)
+			if err != nil {
+				t.Fatalf("%s: reopen Chunk(): %v", name, err)
+			}
+			var got codecUser
+			if ok, err := reopened.Get([]byte("u"), &got); err != nil || !ok || got != (codecUser{Name: "Ada", Age: 36}) {
+				t.Fatalf("%s: Get() = %+v, %v, %v", name, got, ok, err)
+			}
+		}
+	}
+
+	// protobuf кодує proto.Message
+	pb, err := c.Chunk("codec_protobuf", 60, cache.WithCodec(cache.CodecProtobuf))
+	if err != nil {
+		t.Fatalf("Chunk(protobuf): %v", err)
+	}
+	if err := pb.Set([]byte("s"), wrapperspb.String("hello")); err != nil {
+		t.Fatalf("Set(protobuf): %v", err)
+	}
+	if err := pb.Set([]byte("bad"), codecUser{}); !errors.Is(err, cache.ErrNotProtoMessage) {
+		t.Fatalf("Set(non-proto): expected ErrNotProtoMessage, got %v", err)
+	}
+	if err := pb.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(protobuf): %v", err)
+	}
+	pb, err = c.Chunk("codec_protobuf", 60, cache.WithCodec(cache.CodecProtobuf))
+	if err != nil {
+		t.Fatalf("reopen Chunk(protobuf): %v", err)
+	}
+	var s wrapperspb.StringValue
+	if ok, err := pb.Get([]byte("s"), &s); err != nil || !ok || s.GetValue() != "hello" {
+		t.Fatalf("Get(protobuf) = %q, %v, %v", s.GetValue(), ok, err)
+	}
+
+	// чанк іншого кодека не відкривається
+	for _, tc := range []struct {
+		name string
+		opts []cache.ChunkOption
+	}{
+		{"codec_json_plain", nil},
+		{"codec_json_plain", []cache.ChunkOption{cache.WithLazyLoad()}},
+		{"codec_msgpack_plain", []cache.ChunkOption{cache.WithCodec(cache.CodecJSON)}},
+		{"codec_protobuf", []cache.ChunkOption{cache.WithCodec(cache.CodecGob)}},
+	} {
+		if _, err := c.Chunk(tc.name, 60, tc.opts...); !errors.Is(err, cache.ErrChunkCodec) {
+			t.Fatalf("Chunk(%s) with another codec: expected ErrChunkCodec, got %v", tc.name, err)
+		}
+	}
+
+	// маніфест сторінок не залежить від кодека: невідповідність видно при завантаженні сторінки
+	paged, err := c.Chunk("codec_gob_paged", 60, cache.WithPages(4), cache.WithCodec(cache.CodecProtobuf))
+	if err != nil {
+		t.Fatalf("Chunk(codec_gob_paged): %v", err)
+	}
+	if _, ok := paged.GetRaw([]byte("u")); ok || !errors.Is(paged.Err(), cache.ErrChunkCodec) {
+		t.Fatalf("paged chunk with another codec: ok=%v, err=%v", ok, paged.Err())
+	}
+
+	if _, err := c.Chunk("codec_lazy", 60, cache.WithLazyLoad(), cache.WithCodec(cache.CodecJSON)); !errors.Is(err, cache.ErrChunkOptions) {
+		t.Fatalf("WithLazyLoad+CodecJSON: expected ErrChunkOptions, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrNotCounter означає, що за ключем лежить значення, яке не є десятковим int64.
+var ErrNotCounter = errors.New("значення ключа не є лічильником")
+
+// Incr атомарно додає delta до лічильника key і повертає нове значення.
+// Відсутній ключ вважається нулем і створюється з TTL expiriesSecond.
+//
+// Якщо драйвер реалізує CounterDriver, інкремент виконує драйвер (наприклад, у транзакції Badger).
+// Інакше атомарність забезпечує смугастий lock у межах процесу, а момент завершення TTL зберігається
+// в конверті лічильника. В обох випадках наявний лічильник зберігає TTL, заданий при створенні.
+// Для значення, записаного Set, запасний варіант бере залишковий TTL у драйвера (GetWithTTL,
+// як у drivers.TTLDriver); якщо драйвер його не повідомляє, TTL подовжується до expiriesSecond.
+func (ch *Cache) Incr(key []byte, delta int64, expiriesSecond int) (int64, error) {
+	return ch.IncrCtx(context.Background(), key, delta, expiriesSecond)
+}
+
+func (ch *Cache) IncrCtx(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	return ch.incr(ctx, userKey(key), delta, expiriesSecond)
+}
+
+// incr — Incr для ключа сховища (див. userKey).
+func (ch *Cache) incr(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	if cd, ok := ch.dr.(CounterDriver); ok {
+		return cd.Incr(ctx, ch.storageKey(key), delta, expiriesSecond)
+	}
+
+	mu := ch.locks.Get(ch.storageKey(key))
+	mu.Lock()
+	defer mu.Unlock()
+
+	env, exist, ttl, ttlKnown, err := ch.getEntryTTL(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if env.Tombstone {
+		exist = false
+	}
+	n, err := parseCounter(env.Value, exist)
+	if err != nil {
+		return 0, err
+	}
+	n += delta
+
+	counter := envelope{Value: formatCounter(n)}
+	switch {
+	case exist && env.Expire != 0:
+		counter.Expire = env.Expire
+		expiriesSecond = max(int(math.Ceil(time.Duration(env.Expire-ch.now().UnixNano()).Seconds())), 1)
+	case exist && ttlKnown:
+		expiriesSecond = ttl
+	}
+	if counter.Expire == 0 && expiriesSecond > 0 {
+		counter.Expire = ch.now().Add(time.Duration(expiriesSecond) * time.Second).UnixNano()
+	}
+	return n, ch.setEntry(ctx, key, counter, expiriesSecond)
+}
+
+// ttlDriver — драйвер, який повідомляє залишковий TTL ключа (той самий метод, що й у drivers.TTLDriver).
+type ttlDriver interface {
+	GetWithTTL(ctx context.Context, key []byte) (val []byte, ttlSecond int, exist bool, err error)
+}
+
+// getEntryTTL — getEntry разом із залишковим TTL запису в драйвері (0 — без TTL).
+// ttlKnown=false, якщо драйвер не реалізує ttlDriver.
+func (ch *Cache) getEntryTTL(ctx context.Context, key []byte) (env envelope, exist bool, ttl int, ttlKnown bool, err error) {
+	td, ok := ch.dr.(ttlDriver)
+	if !ok {
+		env, exist, err = ch.getEntry(ctx, key)
+		return env, exist, 0, false, err
+	}
+	raw, ttl, exist, err := td.GetWithTTL(ctx, ch.storageKey(key))
+	if err != nil || !exist {
+		return envelope{}, false, 0, true, err
+	}
+	env, exist, err = ch.unwrapEntry(ctx, raw)
+	return env, exist, ttl, true, err
+}
+
+// Decr атомарно віднімає delta від лічильника key (див. Incr).
+func (ch *Cache) Decr(key []byte, delta int64, expiriesSecond int) (int64, error) {
+	return ch.DecrCtx(context.Background(), key, delta, expiriesSecond)
+}
+
+func (ch *Cache) DecrCtx(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	return ch.IncrCtx(ctx, key, -delta, expiriesSecond)
+}
+
+func parseCounter(val []byte, exist bool) (int64, error) {
+	if !exist {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return 0, ErrNotCounter
+	}
+	return n, nil
+}
+
+func formatCounter(n int64) []byte {
+	return strconv.AppendInt(nil, n, 10)
+}
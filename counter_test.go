@@ -0,0 +1,146 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/drivers"
+)
+
+func TestCounterFreeCache(t *testing.T) {
+	testCounter(t, newFreeCache())
+}
+
+func TestCounterBadgerDB(t *testing.T) {
+	testCounter(t, newBadgerCache(t))
+}
+
+func testCounter(t *testing.T, c *cache.Cache) {
+	const (
+		workers = 20
+		perWork = 50
+	)
+	key := []byte("counter")
+
+	runConcurrently(workers, func(int) {
+		for i := 0; i < perWork; i++ {
+			if _, err := c.Incr(key, 2, 60); err != nil {
+				t.Errorf("Incr(): %v", err)
+				return
+			}
+			if _, err := c.Decr(key, 1, 60); err != nil {
+				t.Errorf("Decr(): %v", err)
+				return
+			}
+		}
+	})
+
+	n, err := c.Incr(key, 0, 60)
+	if err != nil {
+		t.Fatalf("Incr(0): %v", err)
+	}
+	if n != workers*perWork {
+		t.Fatalf("expected counter=%d, got %d (lost updates)", workers*perWork, n)
+	}
+
+	// лічильник читається і як звичайне значення
+	val, exist, err := c.Get(key)
+	if err != nil || !exist || string(val) != "1000" {
+		t.Fatalf("Get(): got=%q exist=%v err=%v", val, exist, err)
+	}
+
+	if err := c.Set([]byte("not counter"), []byte("abc"), 60); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if _, err := c.Incr([]byte("not counter"), 1, 60); !errors.Is(err, cache.ErrNotCounter) {
+		t.Fatalf("Incr(): expected ErrNotCounter, got %v", err)
+	}
+}
+
+// plainDriver приховує опціональні інтерфейси драйвера, щоб перевірити запасні шляхи Cache.
+type plainDriver struct {
+	cache.CacheDriver
+}
+
+func TestCounterFallback(t *testing.T) {
+	c := cache.NewCache(plainDriver{newFreeCacheDriver()})
+	testCounter(t, c)
+}
+
+// ttlOnlyDriver приховує всі опціональні інтерфейси драйвера, крім drivers.TTLDriver.
+type ttlOnlyDriver struct {
+	cache.CacheDriver
+	drivers.TTLDriver
+}
+
+// Наявний лічильник зберігає TTL, заданий при створенні, на всіх шляхах Incr.
+func TestCounterKeepsTTL(t *testing.T) {
+	badger, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	defer badger.Close()
+	freecache := newFreeCacheDriver()
+
+	for name, tc := range map[string]struct {
+		dr  cache.CacheDriver
+		ttl drivers.TTLDriver
+	}{
+		"freecache": {freecache, freecache.(drivers.TTLDriver)},
+		"badger":    {badger, badger},
+		"fallback":  {plainDriver{freecache}, freecache.(drivers.TTLDriver)},
+		"tiered":    {drivers.NewTieredDriver(newFreeCacheDriver(), ttlOnlyDriver{badger, badger}), badger},
+	} {
+		c := cache.NewCache(tc.dr)
+		key := []byte("ttl counter " + name)
+		if _, err := c.Incr(key, 1, 5); err != nil {
+			t.Fatalf("%s: Incr(): %v", name, err)
+		}
+		if n, err := c.Incr(key, 1, 3600); err != nil || n != 2 {
+			t.Fatalf("%s: Incr() = %d, %v", name, n, err)
+		}
+		_, ttl, exist, err := tc.ttl.GetWithTTL(context.Background(), key)
+		if err != nil || !exist || ttl < 1 || ttl > 5 {
+			t.Fatalf("%s: counter ttl=%d exist=%v err=%v, want the ttl set at creation", name, ttl, exist, err)
+		}
+	}
+}
+
+// Значення, записане Set, зберігає свій TTL після Incr і без CounterDriver у драйвера.
+func TestCounterKeepsSetTTL(t *testing.T) {
+	freecache, wbInner := newFreeCacheDriver(), newFreeCacheDriver()
+	wb := drivers.NewWriteBehindDriver(wbInner, drivers.WithFlushInterval(time.Hour))
+	defer wb.Close()
+
+	for name, tc := range map[string]struct {
+		dr  cache.CacheDriver
+		ttl drivers.TTLDriver
+	}{
+		"fallback":     {ttlOnlyDriver{freecache, freecache.(drivers.TTLDriver)}, freecache.(drivers.TTLDriver)},
+		"write-behind": {wb, wbInner.(drivers.TTLDriver)},
+	} {
+		c := cache.NewCache(tc.dr)
+		for how, create := range map[string]func(key []byte) error{
+			"set":  func(key []byte) error { return c.Set(key, []byte("5"), 5) },
+			"incr": func(key []byte) (err error) { _, err = c.Incr(key, 5, 5); return err },
+		} {
+			key := []byte(how + " counter " + name)
+			if err := create(key); err != nil {
+				t.Fatalf("%s: %s: %v", name, how, err)
+			}
+			if n, err := c.Incr(key, 1, 3600); err != nil || n != 6 {
+				t.Fatalf("%s: Incr() = %d, %v", name, n, err)
+			}
+			if err := wb.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush(): %v", err)
+			}
+			_, ttl, exist, err := tc.ttl.GetWithTTL(context.Background(), key)
+			if err != nil || !exist || ttl < 1 || ttl > 5 {
+				t.Fatalf("%s: %q ttl=%d exist=%v err=%v, want the original ttl", name, key, ttl, exist, err)
+			}
+		}
+	}
+}
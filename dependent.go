@@ -0,0 +1,88 @@
+package cache
+
+import "context"
+
+// SetDependent записує значення, обчислене з чанків chunks (наприклад, зведення з чанку "orders").
+//
+// Разом зі значенням зберігаються версії чанків (з їхніх versionKey) на момент запису;
+// Get, GetMany, OnSet та інші читання вважають запис відсутнім, щойно хоча б один з чанків
+// закомічено з новішою версією або його versionKey зник (DeleteChunk, TTL). Чанк, якого ще немає,
+// має версію 0; новий чанк починає з унікальної версії (див. newChunkVersion), тож запис не оживає,
+// коли чанк, створений заново, доходить до старої версії. Scan залежності не перевіряє.
+//
+// Як і у SetWithTags, версії читаються під час запису: коміт чанку між завантаженням снапшота,
+// з якого обчислено значення, і SetDependent залишиться непоміченим.
+// Чанки мають належати тому самому простору імен, що й ключ (див. Namespace).
+func (ch *Cache) SetDependent(key, val []byte, expiriesSecond int, chunks ...string) error {
+	return ch.SetDependentCtx(context.Background(), key, val, expiriesSecond, chunks...)
+}
+
+func (ch *Cache) SetDependentCtx(ctx context.Context, key, val []byte, expiriesSecond int, chunks ...string) error {
+	deps, err := ch.chunkVersions(ctx, chunks)
+	if err != nil {
+		return err
+	}
+	return ch.setEntry(ctx, userKey(key), envelope{Value: val, Deps: deps}, expiriesSecond)
+}
+
+// chunkVersions читає поточні версії чанків; відсутній versionKey дає версію 0.
+func (ch *Cache) chunkVersions(ctx context.Context, chunks []string) (map[string]uint64, error) {
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	keys := make([][]byte, len(chunks))
+	for i, name := range chunks {
+		keys[i] = getChunkVersionKey(name)
+	}
+	vals, err := ch.getMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	deps := make(map[string]uint64, len(chunks))
+	for _, name := range chunks {
+		if deps[name], _, err = decodeVersionKey(vals, getChunkVersionKey(name)); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+// entryValid повідомляє, чи актуальні версії тегів і чанків, записані в конверті.
+// Усі лічильники читаються одним getMany.
+func (ch *Cache) entryValid(ctx context.Context, env *envelope) (bool, error) {
+	keys := make([][]byte, 0, len(env.Tags)+len(env.Deps))
+	for tag := range env.Tags {
+		keys = append(keys, getTagKey(tag))
+	}
+	for name := range env.Deps {
+		keys = append(keys, getChunkVersionKey(name))
+	}
+	vals, err := ch.getMany(ctx, keys)
+	if err != nil {
+		return false, err
+	}
+
+	for tag, want := range env.Tags {
+		val, ok := vals[string(getTagKey(tag))]
+		if !ok {
+			return false, nil
+		}
+		ver, err := parseTagVersion(val)
+		if err != nil {
+			return false, err
+		}
+		if ver != want {
+			return false, nil
+		}
+	}
+	for name, want := range env.Deps {
+		ver, _, err := decodeVersionKey(vals, getChunkVersionKey(name))
+		if err != nil {
+			return false, err
+		}
+		if ver != want {
+			return false, nil
+		}
+	}
+	return true, nil
+}
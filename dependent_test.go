@@ -0,0 +1,78 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestSetDependentFreeCache(t *testing.T) {
+	testSetDependent(t, newFreeCache())
+}
+
+func TestSetDependentBadgerDB(t *testing.T) {
+	testSetDependent(t, newBadgerCache(t))
+}
+
+func testSetDependent(t *testing.T, c *cache.Cache) {
+	commit := func(name string, opts ...cache.ChunkOption) {
+		t.Helper()
+		chunk, err := c.Chunk(name, 60, opts...)
+		if err != nil {
+			t.Fatalf("Chunk(%q): %v", name, err)
+		}
+		chunk.SetRaw([]byte("k"), []byte("v"))
+		if err := chunk.SaveChanges(); err != nil {
+			t.Fatalf("SaveChanges(%q): %v", name, err)
+		}
+	}
+	expect := func(key string, want bool) {
+		t.Helper()
+		_, exist, err := c.Get([]byte(key))
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		if exist != want {
+			t.Fatalf("Get(%q): exist=%v, want %v", key, exist, want)
+		}
+	}
+
+	commit("orders")
+	commit("customers", cache.WithPages(4))
+	for key, deps := range map[string][]string{
+		"summary:orders":    {"orders"},
+		"summary:both":      {"orders", "customers"},
+		"summary:customers": {"customers"},
+		"summary:missing":   {"not_created_yet"},
+	} {
+		if err := c.SetDependent([]byte(key), []byte("v"), 60, deps...); err != nil {
+			t.Fatalf("SetDependent(%q): %v", key, err)
+		}
+		expect(key, true)
+	}
+
+	commit("orders")
+	expect("summary:orders", false)
+	expect("summary:both", false)
+	expect("summary:customers", true)
+	expect("summary:missing", true)
+
+	commit("customers", cache.WithPages(4))
+	commit("not_created_yet")
+	expect("summary:customers", false)
+	expect("summary:missing", false)
+
+	if err := c.SetDependent([]byte("summary:orders"), []byte("v"), 60, "orders"); err != nil {
+		t.Fatalf("SetDependent(): %v", err)
+	}
+	expect("summary:orders", true)
+	if err := c.DeleteChunk("orders"); err != nil {
+		t.Fatalf("DeleteChunk(): %v", err)
+	}
+	expect("summary:orders", false)
+
+	// чанк, створений заново, доходить до старої версії, але запис не оживає (ABA)
+	commit("orders")
+	commit("orders")
+	expect("summary:orders", false)
+}
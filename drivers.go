@@ -1,5 +1,7 @@
 package cache
 
+import "context"
+
 type CacheDriver interface {
 	Get(key []byte) (val []byte, exist bool, err error)
 	Set(key, val []byte, expiriesSecond int) error
@@ -11,3 +13,149 @@ type CacheDriver interface {
 	//завершает запись всех значений и закрывает хранилище
 	Close() error
 }
+
+// ContextDriver — опціональний інтерфейс драйвера, який приймає context.Context.
+// Якщо драйвер його реалізує, Cache передає дедлайни та скасування викликача прямо в драйвер;
+// інакше Cache перевіряє ctx.Err() перед викликом звичайних методів CacheDriver.
+type ContextDriver interface {
+	GetCtx(ctx context.Context, key []byte) (val []byte, exist bool, err error)
+	SetCtx(ctx context.Context, key, val []byte, expiriesSecond int) error
+	DelCtx(ctx context.Context, key []byte) error
+	ClearCtx(ctx context.Context) error
+}
+
+// DriverGet, DriverSet, DriverDel і DriverClear викликають Ctx-методи драйвера, якщо він реалізує ContextDriver,
+// інакше перевіряють ctx і викликають звичайні методи CacheDriver.
+// Ними користуються й драйвери-обгортки (див. пакет drivers).
+func DriverGet(ctx context.Context, dr CacheDriver, key []byte) ([]byte, bool, error) {
+	if cd, ok := dr.(ContextDriver); ok {
+		return cd.GetCtx(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	return dr.Get(key)
+}
+
+func DriverSet(ctx context.Context, dr CacheDriver, key, val []byte, expiriesSecond int) error {
+	if cd, ok := dr.(ContextDriver); ok {
+		return cd.SetCtx(ctx, key, val, expiriesSecond)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return dr.Set(key, val, expiriesSecond)
+}
+
+func DriverDel(ctx context.Context, dr CacheDriver, key []byte) error {
+	if cd, ok := dr.(ContextDriver); ok {
+		return cd.DelCtx(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return dr.Del(key)
+}
+
+func DriverClear(ctx context.Context, dr CacheDriver) error {
+	if cd, ok := dr.(ContextDriver); ok {
+		return cd.ClearCtx(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return dr.Clear()
+}
+
+// BatchDriver — опціональний інтерфейс драйвера для пакетних операцій.
+// Драйвери без нього отримують запасний варіант: цикл по одиночних Get/Set/Del.
+type BatchDriver interface {
+	// GetMany повертає лише знайдені ключі.
+	GetMany(ctx context.Context, keys [][]byte) (map[string][]byte, error)
+	SetMany(ctx context.Context, items map[string][]byte, expiriesSecond int) error
+	DelMany(ctx context.Context, keys [][]byte) error
+}
+
+// DriverGetMany, DriverSetMany і DriverDelMany використовують BatchDriver, якщо драйвер його реалізує,
+// інакше обробляють ключі по одному.
+func DriverGetMany(ctx context.Context, dr CacheDriver, keys [][]byte) (map[string][]byte, error) {
+	if bd, ok := dr.(BatchDriver); ok {
+		return bd.GetMany(ctx, keys)
+	}
+	vals := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		val, exist, err := DriverGet(ctx, dr, key)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			vals[string(key)] = val
+		}
+	}
+	return vals, nil
+}
+
+func DriverSetMany(ctx context.Context, dr CacheDriver, items map[string][]byte, expiriesSecond int) error {
+	if bd, ok := dr.(BatchDriver); ok {
+		return bd.SetMany(ctx, items, expiriesSecond)
+	}
+	for key, val := range items {
+		if err := DriverSet(ctx, dr, []byte(key), val, expiriesSecond); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DriverDelMany(ctx context.Context, dr CacheDriver, keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if bd, ok := dr.(BatchDriver); ok {
+		return bd.DelMany(ctx, keys)
+	}
+	for _, key := range keys {
+		if err := DriverDel(ctx, dr, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CounterDriver — опціональний інтерфейс драйвера з нативним атомарним інкрементом.
+// Лічильник зберігається як десятковий рядок (strconv.FormatInt).
+// TTL застосовується лише при створенні ключа: наявний лічильник зберігає свій час життя.
+type CounterDriver interface {
+	Incr(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error)
+}
+
+// AtomicDriver — опціональний інтерфейс драйвера з атомарними умовними записами.
+// Значення порівнюються побайтово в тому вигляді, в якому лежать у драйвері.
+type AtomicDriver interface {
+	// SetIfNotExists записує val, лише якщо ключа немає. Повертає true, якщо запис відбувся.
+	SetIfNotExists(ctx context.Context, key, val []byte, expiriesSecond int) (bool, error)
+	// CompareAndSwap замінює значення на newVal, лише якщо ключ існує і його значення дорівнює old.
+	// Повертає true, якщо заміна відбулась.
+	CompareAndSwap(ctx context.Context, key, old, newVal []byte, expiriesSecond int) (bool, error)
+}
+
+// TxDriver — опціональний інтерфейс драйвера з умовним атомарним записом кількох ключів.
+type TxDriver interface {
+	// CommitIf атомарно записує всі items, лише якщо значення condKey дорівнює old
+	// (old == nil означає, що condKey має бути відсутнім). Повертає true, якщо запис відбувся.
+	CommitIf(ctx context.Context, condKey, old []byte, items map[string][]byte, expiriesSecond int) (bool, error)
+}
+
+// IterableDriver — опціональний інтерфейс драйвера з обходом ключів за префіксом.
+type IterableDriver interface {
+	// Scan викликає fn для кожного живого ключа з префіксом prefix (порожній — усі ключі), доки fn повертає true.
+	// Порядок обходу залежить від драйвера. Ключі та значення, передані в fn, — копії;
+	// fn не повинна змінювати сховище (видалення варто збирати і виконувати після Scan).
+	Scan(ctx context.Context, prefix []byte, fn func(key, val []byte) bool) error
+}
+
+// PrefixDeleter — опціональний інтерфейс драйвера з нативним видаленням усіх ключів з префіксом.
+// Драйвери без нього отримують запасний варіант: Scan + DelMany.
+type PrefixDeleter interface {
+	DelPrefix(ctx context.Context, prefix []byte) error
+}
@@ -1,10 +1,15 @@
 package drivers
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
+
+	"github.com/v-grabko1999/cache"
 )
 
 type BadgerDBDriver struct {
@@ -58,6 +63,13 @@ func runVlogGC(db *badger.DB, discard float64) error {
 }
 
 func (rt *BadgerDBDriver) Get(key []byte) (val []byte, exist bool, err error) {
+	return rt.GetCtx(context.Background(), key)
+}
+
+func (rt *BadgerDBDriver) GetCtx(ctx context.Context, key []byte) (val []byte, exist bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	err = rt.db.View(func(txn *badger.Txn) error {
 		item, e := txn.Get(key)
 		if e != nil {
@@ -75,17 +87,56 @@ func (rt *BadgerDBDriver) Get(key []byte) (val []byte, exist bool, err error) {
 	return
 }
 
-func (rt *BadgerDBDriver) Set(key, value []byte, expiriesSecond int) error {
-	return rt.db.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry(key, value)
-		if expiriesSecond > 0 {
-			e = e.WithTTL(time.Duration(expiriesSecond) * time.Second)
+// GetWithTTL повертає значення і залишковий TTL ключа в секундах (див. TTLDriver).
+func (rt *BadgerDBDriver) GetWithTTL(ctx context.Context, key []byte) (val []byte, ttlSecond int, exist bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	err = rt.db.View(func(txn *badger.Txn) error {
+		item, e := txn.Get(key)
+		if e != nil {
+			if errors.Is(e, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return e
 		}
-		return txn.SetEntry(e)
+		if ttlSecond, exist = itemTTL(item); !exist {
+			return nil
+		}
+		val, e = item.ValueCopy(nil)
+		return e
+	})
+	return
+}
+
+// itemTTL повертає залишковий TTL запису в секундах; false — запис уже прострочений.
+func itemTTL(item *badger.Item) (int, bool) {
+	expiresAt := item.ExpiresAt()
+	if expiresAt == 0 {
+		return 0, true
+	}
+	ttl := int(int64(expiresAt) - time.Now().Unix())
+	return ttl, ttl > 0
+}
+
+func (rt *BadgerDBDriver) Set(key, value []byte, expiriesSecond int) error {
+	return rt.SetCtx(context.Background(), key, value, expiriesSecond)
+}
+
+func (rt *BadgerDBDriver) SetCtx(ctx context.Context, key, value []byte, expiriesSecond int) error {
+	return rt.update(ctx, func(txn *badger.Txn) error {
+		return setEntry(txn, key, value, expiriesSecond)
 	})
 }
 
 func (rt *BadgerDBDriver) Del(key []byte) error {
+	return rt.DelCtx(context.Background(), key)
+}
+
+func (rt *BadgerDBDriver) DelCtx(ctx context.Context, key []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return rt.db.Update(func(txn *badger.Txn) error {
 		err := txn.Delete(key)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -96,9 +147,307 @@ func (rt *BadgerDBDriver) Del(key []byte) error {
 }
 
 func (rt *BadgerDBDriver) Clear() error {
+	return rt.ClearCtx(context.Background())
+}
+
+// ClearCtx перевіряє ctx лише перед стартом: DropAll не підтримує скасування.
+func (rt *BadgerDBDriver) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return rt.db.DropAll()
 }
 
 func (rt *BadgerDBDriver) Close() error {
 	return rt.db.Close()
 }
+
+// GetMany читає всі ключі в одній read-only транзакції.
+func (rt *BadgerDBDriver) GetMany(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	vals := make(map[string][]byte, len(keys))
+	err := rt.db.View(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item, err := txn.Get(key)
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			vals[string(key)] = val
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// GetManyWithTTL читає ключі разом із залишковим TTL в одній read-only транзакції (див. TTLBatchDriver).
+func (rt *BadgerDBDriver) GetManyWithTTL(ctx context.Context, keys [][]byte) (map[string]TTLValue, error) {
+	vals := make(map[string]TTLValue, len(keys))
+	err := rt.db.View(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item, err := txn.Get(key)
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			ttl, ok := itemTTL(item)
+			if !ok {
+				continue
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			vals[string(key)] = TTLValue{Val: val, TTLSecond: ttl}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// Scan обходить ключі з префіксом ітератором Badger (з Seek на prefix) в одній read-only транзакції.
+func (rt *BadgerDBDriver) Scan(ctx context.Context, prefix []byte, fn func(key, val []byte) bool) error {
+	return rt.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if !fn(item.KeyCopy(nil), val) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// DelPrefix видаляє ключі з префіксом через DropPrefix.
+// ctx перевіряється лише перед стартом: DropPrefix не підтримує скасування.
+func (rt *BadgerDBDriver) DelPrefix(ctx context.Context, prefix []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(prefix) == 0 {
+		return rt.db.DropAll()
+	}
+	return rt.db.DropPrefix(prefix)
+}
+
+// SetMany записує всі ключі через WriteBatch.
+func (rt *BadgerDBDriver) SetMany(ctx context.Context, items map[string][]byte, expiriesSecond int) error {
+	wb := rt.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for key, val := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e := badger.NewEntry([]byte(key), val)
+		if expiriesSecond > 0 {
+			e = e.WithTTL(time.Duration(expiriesSecond) * time.Second)
+		}
+		if err := wb.SetEntry(e); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// DelMany видаляє всі ключі через WriteBatch.
+func (rt *BadgerDBDriver) DelMany(ctx context.Context, keys [][]byte) error {
+	wb := rt.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := wb.Delete(key); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// update виконує fn у read-write транзакції і повторює її при badger.ErrConflict,
+// поки ctx не скасовано. Set, SetIfNotExists, CompareAndSwap, CommitIf та Incr проходять через неї,
+// тож скасування враховується однаково.
+func (rt *BadgerDBDriver) update(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := rt.db.Update(func(txn *badger.Txn) error {
+			// транзакція могла чекати на коміт попередніх — не пишемо, якщо ctx уже скасовано
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fn(txn)
+		})
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
+
+// Incr виконує read-modify-write в одній транзакції Badger.
+// Наявний лічильник зберігає свій ExpiresAt.
+func (rt *BadgerDBDriver) Incr(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	var n int64
+	err := rt.update(ctx, func(txn *badger.Txn) error {
+		n = 0
+		e := badger.NewEntry(key, nil)
+
+		item, err := txn.Get(key)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+			if expiriesSecond > 0 {
+				e = e.WithTTL(time.Duration(expiriesSecond) * time.Second)
+			}
+		case err != nil:
+			return err
+		default:
+			e.ExpiresAt = item.ExpiresAt()
+			var perr error
+			if err := item.Value(func(v []byte) error {
+				n, perr = strconv.ParseInt(string(v), 10, 64)
+				return nil
+			}); err != nil {
+				return err
+			}
+			if perr != nil {
+				return cache.ErrNotCounter
+			}
+		}
+
+		n += delta
+		e.Value = strconv.AppendInt(nil, n, 10)
+		return txn.SetEntry(e)
+	})
+	return n, err
+}
+
+// setEntry записує key/value з TTL у межах транзакції txn.
+func setEntry(txn *badger.Txn, key, value []byte, expiriesSecond int) error {
+	e := badger.NewEntry(key, value)
+	if expiriesSecond > 0 {
+		e = e.WithTTL(time.Duration(expiriesSecond) * time.Second)
+	}
+	return txn.SetEntry(e)
+}
+
+// SetIfNotExists виконує перевірку і запис в одній транзакції Badger.
+func (rt *BadgerDBDriver) SetIfNotExists(ctx context.Context, key, val []byte, expiriesSecond int) (bool, error) {
+	var ok bool
+	err := rt.update(ctx, func(txn *badger.Txn) error {
+		ok = false
+		_, err := txn.Get(key)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			return nil
+		}
+		ok = true
+		return setEntry(txn, key, val, expiriesSecond)
+	})
+	return ok, err
+}
+
+// CompareAndSwap виконує порівняння і запис в одній транзакції Badger.
+// Паралельний запис того ж ключа призводить до badger.ErrConflict і повтору транзакції.
+func (rt *BadgerDBDriver) CompareAndSwap(ctx context.Context, key, old, newVal []byte, expiriesSecond int) (bool, error) {
+	var ok bool
+	err := rt.update(ctx, func(txn *badger.Txn) error {
+		ok = false
+		item, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var equal bool
+		if err := item.Value(func(v []byte) error {
+			equal = bytes.Equal(v, old)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if !equal {
+			return nil
+		}
+		ok = true
+		return setEntry(txn, key, newVal, expiriesSecond)
+	})
+	return ok, err
+}
+
+// CommitIf перевіряє condKey і записує всі items в одній транзакції Badger.
+func (rt *BadgerDBDriver) CommitIf(ctx context.Context, condKey, old []byte, items map[string][]byte, expiriesSecond int) (bool, error) {
+	var ok bool
+	err := rt.update(ctx, func(txn *badger.Txn) error {
+		ok = false
+		item, err := txn.Get(condKey)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+			if old != nil {
+				return nil
+			}
+		case err != nil:
+			return err
+		default:
+			if old == nil {
+				return nil
+			}
+			var equal bool
+			if err := item.Value(func(v []byte) error {
+				equal = bytes.Equal(v, old)
+				return nil
+			}); err != nil {
+				return err
+			}
+			if !equal {
+				return nil
+			}
+		}
+
+		for key, val := range items {
+			if err := setEntry(txn, []byte(key), val, expiriesSecond); err != nil {
+				return err
+			}
+		}
+		ok = true
+		return nil
+	})
+	return ok, err
+}
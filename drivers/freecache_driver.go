@@ -1,19 +1,27 @@
 package drivers
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"strconv"
+	"time"
 
 	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/internal/stripe"
 
 	"github.com/coocood/freecache"
 )
 
 func NewFreeCacheDriver(ch *freecache.Cache) cache.CacheDriver {
-	return &FreeCacheDriver{ch}
+	return &FreeCacheDriver{ch: ch}
 }
 
 type FreeCacheDriver struct {
 	ch *freecache.Cache
+
+	// locks серіалізує read-modify-write операції (Incr, SetIfNotExists, CompareAndSwap, CommitIf) для одного ключа.
+	locks stripe.Lock
 }
 
 var (
@@ -21,6 +29,15 @@ var (
 )
 
 func (rt *FreeCacheDriver) Get(key []byte) (val []byte, exist bool, err error) {
+	return rt.GetCtx(context.Background(), key)
+}
+
+// Операції freecache виконуються в памʼяті й не блокуються,
+// тому Ctx-методи лише перевіряють, чи ctx ще не скасовано.
+func (rt *FreeCacheDriver) GetCtx(ctx context.Context, key []byte) (val []byte, exist bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	val, err = rt.ch.Get(key)
 	if err != nil {
 		if errors.Is(err, freecache.ErrNotFound) {
@@ -33,16 +50,57 @@ func (rt *FreeCacheDriver) Get(key []byte) (val []byte, exist bool, err error) {
 	return
 }
 
+// GetWithTTL повертає значення і залишковий TTL ключа в секундах (див. TTLDriver).
+func (rt *FreeCacheDriver) GetWithTTL(ctx context.Context, key []byte) (val []byte, ttlSecond int, exist bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	val, expireAt, err := rt.ch.GetWithExpiration(key)
+	if err != nil {
+		if errors.Is(err, freecache.ErrNotFound) {
+			err = nil
+		}
+		return nil, 0, false, err
+	}
+	if expireAt > 0 {
+		if ttlSecond = int(int64(expireAt) - time.Now().Unix()); ttlSecond <= 0 {
+			return nil, 0, false, nil
+		}
+	}
+	return val, ttlSecond, true, nil
+}
+
 func (rt *FreeCacheDriver) Set(key []byte, val []byte, expiriesSecond int) error {
+	return rt.SetCtx(context.Background(), key, val, expiriesSecond)
+}
+
+func (rt *FreeCacheDriver) SetCtx(ctx context.Context, key []byte, val []byte, expiriesSecond int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return rt.ch.Set(key, val, expiriesSecond)
 }
 
 func (rt *FreeCacheDriver) Del(key []byte) error {
+	return rt.DelCtx(context.Background(), key)
+}
+
+func (rt *FreeCacheDriver) DelCtx(ctx context.Context, key []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	rt.ch.Del(key)
 	return nil
 }
 
 func (rt *FreeCacheDriver) Clear() error {
+	return rt.ClearCtx(context.Background())
+}
+
+func (rt *FreeCacheDriver) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	rt.ch.Clear()
 	return nil
 }
@@ -50,3 +108,125 @@ func (rt *FreeCacheDriver) Clear() error {
 func (rt *FreeCacheDriver) Close() error {
 	return nil
 }
+
+// Scan — повний обхід freecache через NewIterator з фільтром за префіксом:
+// freecache не впорядковує ключі, тож ціна обходу не залежить від префікса.
+func (rt *FreeCacheDriver) Scan(ctx context.Context, prefix []byte, fn func(key, val []byte) bool) error {
+	it := rt.ch.NewIterator()
+	for e := it.Next(); e != nil; e = it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if bytes.HasPrefix(e.Key, prefix) && !fn(e.Key, e.Value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Incr виконує get+set під смугастим lock-ом драйвера.
+// Наявний лічильник зберігає свій залишковий TTL.
+func (rt *FreeCacheDriver) Incr(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var n int64
+	val, expireAt, err := rt.ch.GetWithExpiration(key)
+	switch {
+	case errors.Is(err, freecache.ErrNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		if n, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+			return 0, cache.ErrNotCounter
+		}
+		expiriesSecond = remainingSeconds(expireAt)
+	}
+
+	n += delta
+	return n, rt.ch.Set(key, strconv.AppendInt(nil, n, 10), expiriesSecond)
+}
+
+// remainingSeconds перетворює абсолютний expireAt freecache на залишковий TTL.
+// 0 означає "без TTL"; для ключа, що ось-ось зникне, повертаємо мінімум 1 секунду.
+func remainingSeconds(expireAt uint32) int {
+	if expireAt == 0 {
+		return 0
+	}
+	return max(int(int64(expireAt)-time.Now().Unix()), 1)
+}
+
+// SetIfNotExists виконує перевірку і запис під смугастим lock-ом драйвера.
+func (rt *FreeCacheDriver) SetIfNotExists(ctx context.Context, key, val []byte, expiriesSecond int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, exist, err := rt.Get(key)
+	if err != nil || exist {
+		return false, err
+	}
+	return true, rt.ch.Set(key, val, expiriesSecond)
+}
+
+// CompareAndSwap виконує порівняння і запис під смугастим lock-ом драйвера.
+func (rt *FreeCacheDriver) CompareAndSwap(ctx context.Context, key, old, newVal []byte, expiriesSecond int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	cur, exist, err := rt.Get(key)
+	if err != nil || !exist || !bytes.Equal(cur, old) {
+		return false, err
+	}
+	return true, rt.ch.Set(key, newVal, expiriesSecond)
+}
+
+// CommitIf перевіряє condKey і записує items під смугастим lock-ом condKey.
+// Атомарність гарантується відносно інших CommitIf з тим самим condKey у межах процесу;
+// condKey записується останнім. Записи послідовні, тож помилка між ними залишає частину items записаною.
+func (rt *FreeCacheDriver) CommitIf(ctx context.Context, condKey, old []byte, items map[string][]byte, expiriesSecond int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mu := rt.locks.Get(condKey)
+	mu.Lock()
+	defer mu.Unlock()
+
+	cur, exist, err := rt.Get(condKey)
+	if err != nil {
+		return false, err
+	}
+	if exist != (old != nil) || !bytes.Equal(cur, old) {
+		return false, nil
+	}
+
+	for key, val := range items {
+		if key == string(condKey) {
+			continue
+		}
+		if err := rt.ch.Set([]byte(key), val, expiriesSecond); err != nil {
+			return false, err
+		}
+	}
+	if val, ok := items[string(condKey)]; ok {
+		if err := rt.ch.Set(condKey, val, expiriesSecond); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
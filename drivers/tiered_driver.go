@@ -0,0 +1,489 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/internal/stripe"
+)
+
+// TTLDriver — опціональний інтерфейс драйвера, який повідомляє залишковий TTL ключа.
+// TieredDriver використовує його, щоб запис, піднятий у L1, не пережив запис у L2.
+type TTLDriver interface {
+	// GetWithTTL повертає значення і залишковий TTL у секундах (0 — без TTL).
+	GetWithTTL(ctx context.Context, key []byte) (val []byte, ttlSecond int, exist bool, err error)
+}
+
+// TTLValue — значення ключа і його залишковий TTL у секундах (0 — без TTL).
+type TTLValue struct {
+	Val       []byte
+	TTLSecond int
+}
+
+// TTLBatchDriver — пакетний варіант TTLDriver: TieredDriver.GetMany читає ним промахи L1
+// одним зверненням до L2 замість GetWithTTL на кожен ключ.
+type TTLBatchDriver interface {
+	// GetManyWithTTL повертає знайдені ключі; відсутніх ключів у map немає.
+	GetManyWithTTL(ctx context.Context, keys [][]byte) (map[string]TTLValue, error)
+}
+
+// TieredOption налаштовує TieredDriver (див. NewTieredDriver).
+type TieredOption func(*TieredDriver)
+
+// WithWriteAround вмикає режим write-around: Set пише лише в L2 і видаляє ключ з L1,
+// а в L1 значення потрапляє при першому читанні. За замовчуванням — write-through (Set пише в обидва рівні).
+func WithWriteAround() TieredOption {
+	return func(rt *TieredDriver) {
+		rt.writeAround = true
+	}
+}
+
+// WithL1MaxTTL обмежує час життя записів L1 (у тому числі записів L2 без TTL).
+// Корисно, коли L2 ділять кілька процесів: чужі записи в L2 стають видимими не пізніше ніж через seconds.
+func WithL1MaxTTL(seconds int) TieredOption {
+	return func(rt *TieredDriver) {
+		rt.l1MaxTTL = seconds
+	}
+}
+
+// NewTieredDriver обʼєднує два драйвери в дворівневий кеш: l1 (наприклад, freecache у памʼяті процесу)
+// перед l2 (наприклад, BadgerDB).
+func NewTieredDriver(l1, l2 cache.CacheDriver, opts ...TieredOption) *TieredDriver {
+	rt := &TieredDriver{l1: l1, l2: l2}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+// TieredDriver — дворівневий драйвер: читання йдуть спершу в L1, промах читається з L2
+// і піднімається в L1 із залишковим TTL запису L2, тож L1 ніколи не переживає L2.
+// Якщо L2 не реалізує TTLDriver (або TTLBatchDriver для GetMany), записи з L2 у L1 не піднімаються.
+//
+// L2 — джерело істини: умовні записи (SetIfNotExists, CompareAndSwap, CommitIf, Incr),
+// Scan і DelPrefix виконуються в L2 (нативно, якщо L2 їх підтримує), а змінені ключі видаляються з L1.
+// Записи і видалення ключа в межах процесу не перемежовуються з читаннями цього ключа з L2,
+// тож L1 не отримує застарілого значення від паралельного читання; самі читання одне одного не блокують.
+// Записи інших процесів у спільний L2 стають видимими після завершення TTL запису L1 (див. WithL1MaxTTL).
+type TieredDriver struct {
+	l1, l2 cache.CacheDriver
+
+	writeAround bool
+	l1MaxTTL    int
+
+	// locks серіалізує записи ключа; читання з підняттям у L1 беруть його на читання.
+	locks stripe.RWLock
+}
+
+func (rt *TieredDriver) Get(key []byte) (val []byte, exist bool, err error) {
+	return rt.GetCtx(context.Background(), key)
+}
+
+func (rt *TieredDriver) GetCtx(ctx context.Context, key []byte) (val []byte, exist bool, err error) {
+	val, exist, err = cache.DriverGet(ctx, rt.l1, key)
+	if err != nil || exist {
+		return
+	}
+	td, ok := rt.l2.(TTLDriver)
+	if !ok {
+		return cache.DriverGet(ctx, rt.l2, key)
+	}
+
+	mu := rt.locks.Get(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	val, ttl, exist, err := td.GetWithTTL(ctx, key)
+	if err != nil || !exist {
+		return nil, false, err
+	}
+	rt.fill(ctx, key, val, ttl)
+	return val, true, nil
+}
+
+func (rt *TieredDriver) Set(key, val []byte, expiriesSecond int) error {
+	return rt.SetCtx(context.Background(), key, val, expiriesSecond)
+}
+
+func (rt *TieredDriver) SetCtx(ctx context.Context, key, val []byte, expiriesSecond int) error {
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := cache.DriverSet(ctx, rt.l2, key, val, expiriesSecond); err != nil {
+		return err
+	}
+	if rt.writeAround {
+		return rt.invalidate(ctx, key)
+	}
+	rt.fill(ctx, key, val, expiriesSecond)
+	return nil
+}
+
+func (rt *TieredDriver) Del(key []byte) error {
+	return rt.DelCtx(context.Background(), key)
+}
+
+func (rt *TieredDriver) DelCtx(ctx context.Context, key []byte) error {
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := cache.DriverDel(ctx, rt.l2, key); err != nil {
+		return err
+	}
+	return rt.invalidate(ctx, key)
+}
+
+func (rt *TieredDriver) Clear() error {
+	return rt.ClearCtx(context.Background())
+}
+
+func (rt *TieredDriver) ClearCtx(ctx context.Context) error {
+	if err := cache.DriverClear(ctx, rt.l2); err != nil {
+		return err
+	}
+	return cache.DriverClear(context.WithoutCancel(ctx), rt.l1)
+}
+
+// Close закриває обидва рівні.
+func (rt *TieredDriver) Close() error {
+	return errors.Join(rt.l1.Close(), rt.l2.Close())
+}
+
+// l1TTL обмежує TTL запису L1 значенням WithL1MaxTTL.
+func (rt *TieredDriver) l1TTL(ttl int) int {
+	if rt.l1MaxTTL > 0 && (ttl == 0 || ttl > rt.l1MaxTTL) {
+		return rt.l1MaxTTL
+	}
+	return ttl
+}
+
+// fill записує значення в L1. Якщо запис не вдався (наприклад, значення завелике для freecache),
+// ключ видаляється з L1, щоб там не лишилося попереднього значення.
+// Викликається під lock-ом ключа; скасування ctx не перериває узгодження L1 з L2.
+func (rt *TieredDriver) fill(ctx context.Context, key, val []byte, ttl int) {
+	ctx = context.WithoutCancel(ctx)
+	if err := cache.DriverSet(ctx, rt.l1, key, val, rt.l1TTL(ttl)); err != nil {
+		_ = cache.DriverDel(ctx, rt.l1, key)
+	}
+}
+
+// invalidate видаляє ключі з L1 після зміни L2.
+func (rt *TieredDriver) invalidate(ctx context.Context, keys ...[]byte) error {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := cache.DriverDel(ctx, rt.l1, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMany читає ключі під lock-ами їхніх смуг на читання: записи цих ключів у межах процесу
+// не перемежовуються з читанням, тож GetMany не бачить половини SetMany чи CommitIf.
+// Великий пакет на час читання затримує записи своїх смуг, але не інші читання.
+//
+// Промахи L1 читаються з L2 одним зверненням через TTLBatchDriver і піднімаються в L1.
+// Якщо L2 реалізує лише TTLDriver, промахи читаються по одному; без TTLDriver — через
+// GetMany рівня L2 без підняття в L1.
+func (rt *TieredDriver) GetMany(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	unlock := rt.locks.RLockAll(keys)
+	defer unlock()
+
+	vals := make(map[string][]byte, len(keys))
+	var misses [][]byte
+	for _, key := range keys {
+		val, exist, err := cache.DriverGet(ctx, rt.l1, key)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			vals[string(key)] = val
+		} else {
+			misses = append(misses, key)
+		}
+	}
+	if len(misses) == 0 {
+		return vals, nil
+	}
+
+	l2vals, err := rt.getManyWithTTL(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range l2vals {
+		vals[key] = v.Val
+		if v.TTLSecond >= 0 {
+			rt.fill(ctx, []byte(key), v.Val, v.TTLSecond)
+		}
+	}
+	return vals, nil
+}
+
+// getManyWithTTL читає ключі з L2 разом із залишковим TTL (див. GetMany).
+// Якщо L2 не повідомляє TTL, значення мають TTLSecond = -1 і в L1 не піднімаються.
+func (rt *TieredDriver) getManyWithTTL(ctx context.Context, keys [][]byte) (map[string]TTLValue, error) {
+	if bd, ok := rt.l2.(TTLBatchDriver); ok {
+		return bd.GetManyWithTTL(ctx, keys)
+	}
+	vals := make(map[string]TTLValue, len(keys))
+	td, ok := rt.l2.(TTLDriver)
+	if !ok {
+		l2vals, err := cache.DriverGetMany(ctx, rt.l2, keys)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range l2vals {
+			vals[key] = TTLValue{Val: val, TTLSecond: -1}
+		}
+		return vals, nil
+	}
+	for _, key := range keys {
+		val, ttl, exist, err := td.GetWithTTL(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			vals[string(key)] = TTLValue{Val: val, TTLSecond: ttl}
+		}
+	}
+	return vals, nil
+}
+
+// SetMany записує ключі в L2 одним пакетом, а потім у L1 (або видаляє їх з L1 у режимі write-around).
+func (rt *TieredDriver) SetMany(ctx context.Context, items map[string][]byte, expiriesSecond int) error {
+	keys := make([][]byte, 0, len(items))
+	for key := range items {
+		keys = append(keys, []byte(key))
+	}
+	unlock := rt.locks.LockAll(keys)
+	defer unlock()
+
+	if err := cache.DriverSetMany(ctx, rt.l2, items, expiriesSecond); err != nil {
+		return err
+	}
+	if rt.writeAround {
+		return rt.invalidate(ctx, keys...)
+	}
+	for key, val := range items {
+		rt.fill(ctx, []byte(key), val, expiriesSecond)
+	}
+	return nil
+}
+
+// DelMany видаляє ключі з обох рівнів.
+func (rt *TieredDriver) DelMany(ctx context.Context, keys [][]byte) error {
+	unlock := rt.locks.LockAll(keys)
+	defer unlock()
+
+	if err := cache.DriverDelMany(ctx, rt.l2, keys); err != nil {
+		return err
+	}
+	return rt.invalidate(ctx, keys...)
+}
+
+// Incr виконується в L2 (див. cache.CounterDriver); ключ видаляється з L1.
+func (rt *TieredDriver) Incr(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var (
+		n   int64
+		err error
+	)
+	if cd, ok := rt.l2.(cache.CounterDriver); ok {
+		n, err = cd.Incr(ctx, key, delta, expiriesSecond)
+	} else {
+		n, err = rt.incrLocked(ctx, key, delta, expiriesSecond)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, rt.invalidate(ctx, key)
+}
+
+// incrLocked — інкремент у L2 без нативної підтримки; атомарність дає lock ключа.
+// Як і нативний Incr, наявний лічильник зберігає свій TTL, якщо L2 реалізує TTLDriver;
+// інакше залишковий TTL невідомий, і лічильник переписується з expiriesSecond.
+func (rt *TieredDriver) incrLocked(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	var (
+		val   []byte
+		ttl   int
+		exist bool
+		err   error
+	)
+	if td, ok := rt.l2.(TTLDriver); ok {
+		val, ttl, exist, err = td.GetWithTTL(ctx, key)
+	} else {
+		val, exist, err = cache.DriverGet(ctx, rt.l2, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if exist {
+		if n, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+			return 0, cache.ErrNotCounter
+		}
+		if _, ok := rt.l2.(TTLDriver); ok {
+			expiriesSecond = ttl
+		}
+	}
+	n += delta
+	return n, cache.DriverSet(ctx, rt.l2, key, strconv.AppendInt(nil, n, 10), expiriesSecond)
+}
+
+// SetIfNotExists виконується в L2 (див. cache.AtomicDriver); записаний ключ видаляється з L1.
+func (rt *TieredDriver) SetIfNotExists(ctx context.Context, key, val []byte, expiriesSecond int) (bool, error) {
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var (
+		ok  bool
+		err error
+	)
+	if ad, isAtomic := rt.l2.(cache.AtomicDriver); isAtomic {
+		ok, err = ad.SetIfNotExists(ctx, key, val, expiriesSecond)
+	} else {
+		var exist bool
+		if _, exist, err = cache.DriverGet(ctx, rt.l2, key); err == nil && !exist {
+			ok, err = true, cache.DriverSet(ctx, rt.l2, key, val, expiriesSecond)
+		}
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, rt.invalidate(ctx, key)
+}
+
+// CompareAndSwap виконується в L2 (див. cache.AtomicDriver); замінений ключ видаляється з L1.
+func (rt *TieredDriver) CompareAndSwap(ctx context.Context, key, old, newVal []byte, expiriesSecond int) (bool, error) {
+	mu := rt.locks.Get(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var (
+		ok  bool
+		err error
+	)
+	if ad, isAtomic := rt.l2.(cache.AtomicDriver); isAtomic {
+		ok, err = ad.CompareAndSwap(ctx, key, old, newVal, expiriesSecond)
+	} else {
+		var (
+			cur   []byte
+			exist bool
+		)
+		if cur, exist, err = cache.DriverGet(ctx, rt.l2, key); err == nil && exist && bytes.Equal(cur, old) {
+			ok, err = true, cache.DriverSet(ctx, rt.l2, key, newVal, expiriesSecond)
+		}
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, rt.invalidate(ctx, key)
+}
+
+// CommitIf виконується в L2 (див. cache.TxDriver); записані ключі видаляються з L1.
+// На час коміту блокуються смуги всіх ключів items.
+func (rt *TieredDriver) CommitIf(ctx context.Context, condKey, old []byte, items map[string][]byte, expiriesSecond int) (bool, error) {
+	keys := make([][]byte, 0, len(items)+1)
+	keys = append(keys, condKey)
+	for key := range items {
+		keys = append(keys, []byte(key))
+	}
+	unlock := rt.locks.LockAll(keys)
+	defer unlock()
+
+	var (
+		ok  bool
+		err error
+	)
+	if td, isTx := rt.l2.(cache.TxDriver); isTx {
+		ok, err = td.CommitIf(ctx, condKey, old, items, expiriesSecond)
+	} else {
+		ok, err = rt.commitIfLocked(ctx, condKey, old, items, expiriesSecond)
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, rt.invalidate(ctx, keys...)
+}
+
+// commitIfLocked — CommitIf у L2 без нативної підтримки; condKey записується останнім.
+func (rt *TieredDriver) commitIfLocked(ctx context.Context, condKey, old []byte, items map[string][]byte, expiriesSecond int) (bool, error) {
+	cur, exist, err := cache.DriverGet(ctx, rt.l2, condKey)
+	if err != nil {
+		return false, err
+	}
+	if exist != (old != nil) || !bytes.Equal(cur, old) {
+		return false, nil
+	}
+	for key, val := range items {
+		if key == string(condKey) {
+			continue
+		}
+		if err := cache.DriverSet(ctx, rt.l2, []byte(key), val, expiriesSecond); err != nil {
+			return false, err
+		}
+	}
+	if val, ok := items[string(condKey)]; ok {
+		if err := cache.DriverSet(ctx, rt.l2, condKey, val, expiriesSecond); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Scan обходить L2 (див. cache.IterableDriver).
+func (rt *TieredDriver) Scan(ctx context.Context, prefix []byte, fn func(key, val []byte) bool) error {
+	id, ok := rt.l2.(cache.IterableDriver)
+	if !ok {
+		return cache.ErrNotIterable
+	}
+	return id.Scan(ctx, prefix, fn)
+}
+
+// DelPrefix видаляє ключі з префіксом в обох рівнях (див. cache.PrefixDeleter).
+// Якщо L1 не підтримує обходу ключів, він очищається повністю.
+func (rt *TieredDriver) DelPrefix(ctx context.Context, prefix []byte) error {
+	if err := delPrefix(ctx, rt.l2, prefix); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	err := delPrefix(ctx, rt.l1, prefix)
+	if errors.Is(err, cache.ErrNotIterable) {
+		return cache.DriverClear(ctx, rt.l1)
+	}
+	return err
+}
+
+// delPrefix видаляє ключі з префіксом через PrefixDeleter або обхід з видаленням.
+func delPrefix(ctx context.Context, dr cache.CacheDriver, prefix []byte) error {
+	if pd, ok := dr.(cache.PrefixDeleter); ok {
+		return pd.DelPrefix(ctx, prefix)
+	}
+	id, ok := dr.(cache.IterableDriver)
+	if !ok {
+		return cache.ErrNotIterable
+	}
+	var keys [][]byte
+	err := id.Scan(ctx, prefix, func(key, _ []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := cache.DriverDel(ctx, dr, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,535 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+// ErrDriverClosed повертається записами в WriteBehindDriver після Close.
+var ErrDriverClosed = errors.New("драйвер закрито")
+
+// WriteBehindOption налаштовує WriteBehindDriver (див. NewWriteBehindDriver).
+type WriteBehindOption func(*WriteBehindDriver)
+
+// WithFlushInterval задає період фонового скидання буфера (за замовчуванням 100 мс;
+// нульовий чи відʼємний d теж дає 100 мс).
+func WithFlushInterval(d time.Duration) WriteBehindOption {
+	return func(rt *WriteBehindDriver) {
+		rt.interval = d
+	}
+}
+
+// WithFlushSize задає кількість ключів у буфері, після якої скидання запускається, не чекаючи таймера
+// (за замовчуванням 1000).
+func WithFlushSize(n int) WriteBehindOption {
+	return func(rt *WriteBehindDriver) {
+		rt.flushSize = n
+	}
+}
+
+// WithMaxPending задає максимальну кількість ключів у буфері (за замовчуванням 10 × WithFlushSize).
+// Коли буфер повний, Set і Del чекають на завершення скидання (або на скасування ctx).
+func WithMaxPending(n int) WriteBehindOption {
+	return func(rt *WriteBehindDriver) {
+		rt.maxPending = n
+	}
+}
+
+// NewWriteBehindDriver загортає inner у драйвер з відкладеним записом і запускає фонове скидання.
+func NewWriteBehindDriver(inner cache.CacheDriver, opts ...WriteBehindOption) *WriteBehindDriver {
+	rt := &WriteBehindDriver{
+		inner:     inner,
+		interval:  100 * time.Millisecond,
+		flushSize: 1000,
+		pending:   make(map[string]writeOp),
+		drained:   make(chan struct{}),
+		kick:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	if rt.interval <= 0 {
+		rt.interval = 100 * time.Millisecond
+	}
+	rt.flushSize = max(rt.flushSize, 1)
+	if rt.maxPending <= 0 {
+		rt.maxPending = 10 * rt.flushSize
+	}
+
+	rt.wg.Add(1)
+	go rt.loop()
+	return rt
+}
+
+// WriteBehindDriver буферизує Set і Del у памʼяті й записує їх у inner пакетами
+// (через cache.BatchDriver, якщо inner його реалізує, — для Badger це WriteBatch замість транзакції на ключ).
+//
+// Читання бачать ще не записані зміни. Для одного ключа в буфері лишається лише остання зміна;
+// TTL відлічується від виклику Set, а не від скидання. Буфер скидається за таймером (WithFlushInterval),
+// після WithFlushSize ключів, викликом Flush і при Close. Якщо пакет не вдалося записати, його зміни
+// втрачаються (як при витісненні з кешу), а помилку фонового скидання повертає наступний Flush або Close.
+// Скасування ctx у Flush, Scan чи DelPrefix не перериває запис пакета, який уже почав скидатися.
+//
+// GetMany бачить узгоджений знімок буфера, а SetMany, DelMany і CommitIf додають зміни в буфер атомарно.
+// Умовні записи (CommitIf, SetIfNotExists, CompareAndSwap, Incr) перевіряють буфер і inner під lock-ом
+// буфера й атомарні лише в межах процесу: інші процеси не бачать буфера.
+type WriteBehindDriver struct {
+	inner cache.CacheDriver
+
+	interval   time.Duration
+	flushSize  int
+	maxPending int
+
+	mu sync.Mutex
+	// pending — зміни, які ще не почали записуватись; flushing — пакет, що записується зараз.
+	pending  map[string]writeOp
+	flushing map[string]writeOp
+	// drained закривається після кожного скидання; на ньому чекають записи при повному буфері.
+	drained chan struct{}
+	// err — помилка фонового скидання, яку поверне Flush або Close.
+	err    error
+	closed bool
+
+	// flushMu серіалізує скидання і Clear.
+	flushMu sync.Mutex
+	kick    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// writeOp — відкладена зміна ключа.
+type writeOp struct {
+	val []byte
+	del bool
+	// expireAt — момент завершення TTL; нульовий — без TTL.
+	expireAt time.Time
+}
+
+func newSetOp(val []byte, expiriesSecond int) writeOp {
+	return writeOp{val: bytes.Clone(val), expireAt: expireAt(expiriesSecond)}
+}
+
+// expireAt повертає момент завершення TTL, відлічений від поточного моменту; нульовий — без TTL.
+func expireAt(expiriesSecond int) time.Time {
+	if expiriesSecond <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(expiriesSecond) * time.Second)
+}
+
+func (op writeOp) expired(now time.Time) bool {
+	return !op.expireAt.IsZero() && !now.Before(op.expireAt)
+}
+
+// value повертає копію значення, яке бачать читання.
+func (op writeOp) value(now time.Time) ([]byte, bool) {
+	if op.del || op.expired(now) {
+		return nil, false
+	}
+	return bytes.Clone(op.val), true
+}
+
+func (rt *WriteBehindDriver) Get(key []byte) (val []byte, exist bool, err error) {
+	return rt.GetCtx(context.Background(), key)
+}
+
+func (rt *WriteBehindDriver) GetCtx(ctx context.Context, key []byte) (val []byte, exist bool, err error) {
+	rt.mu.Lock()
+	op, ok := rt.lookup(key)
+	rt.mu.Unlock()
+
+	if !ok {
+		return cache.DriverGet(ctx, rt.inner, key)
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	val, exist = op.value(time.Now())
+	return
+}
+
+// lookup шукає ключ у буфері. Викликається під mu.
+func (rt *WriteBehindDriver) lookup(key []byte) (writeOp, bool) {
+	if op, ok := rt.pending[string(key)]; ok {
+		return op, true
+	}
+	op, ok := rt.flushing[string(key)]
+	return op, ok
+}
+
+// getLocked читає ключ з буфера або inner. Викликається під mu: inner змінюється лише для ключів
+// пакета, що записується, а їх lookup знаходить у flushing, тож результат узгоджений з буфером.
+func (rt *WriteBehindDriver) getLocked(ctx context.Context, key []byte) ([]byte, bool, error) {
+	if op, ok := rt.lookup(key); ok {
+		val, exist := op.value(time.Now())
+		return val, exist, ctx.Err()
+	}
+	return cache.DriverGet(ctx, rt.inner, key)
+}
+
+// GetMany читає ключі під mu, тож результат — узгоджений знімок (див. getLocked).
+func (rt *WriteBehindDriver) GetMany(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	now := time.Now()
+	vals := make(map[string][]byte, len(keys))
+	var misses [][]byte
+	for _, key := range keys {
+		op, ok := rt.lookup(key)
+		if !ok {
+			misses = append(misses, key)
+			continue
+		}
+		if val, exist := op.value(now); exist {
+			vals[string(key)] = val
+		}
+	}
+	if len(misses) > 0 {
+		inner, err := cache.DriverGetMany(ctx, rt.inner, misses)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range inner {
+			vals[key] = val
+		}
+	}
+	return vals, ctx.Err()
+}
+
+func (rt *WriteBehindDriver) Set(key, val []byte, expiriesSecond int) error {
+	return rt.SetCtx(context.Background(), key, val, expiriesSecond)
+}
+
+func (rt *WriteBehindDriver) SetCtx(ctx context.Context, key, val []byte, expiriesSecond int) error {
+	_, err := rt.enqueue(ctx, map[string]writeOp{string(key): newSetOp(val, expiriesSecond)}, nil)
+	return err
+}
+
+func (rt *WriteBehindDriver) Del(key []byte) error {
+	return rt.DelCtx(context.Background(), key)
+}
+
+func (rt *WriteBehindDriver) DelCtx(ctx context.Context, key []byte) error {
+	_, err := rt.enqueue(ctx, map[string]writeOp{string(key): {del: true}}, nil)
+	return err
+}
+
+// SetMany додає всі записи в буфер атомарно відносно читань.
+func (rt *WriteBehindDriver) SetMany(ctx context.Context, items map[string][]byte, expiriesSecond int) error {
+	ops := make(map[string]writeOp, len(items))
+	for key, val := range items {
+		ops[key] = newSetOp(val, expiriesSecond)
+	}
+	_, err := rt.enqueue(ctx, ops, nil)
+	return err
+}
+
+// DelMany додає всі видалення в буфер атомарно відносно читань.
+func (rt *WriteBehindDriver) DelMany(ctx context.Context, keys [][]byte) error {
+	ops := make(map[string]writeOp, len(keys))
+	for _, key := range keys {
+		ops[string(key)] = writeOp{del: true}
+	}
+	_, err := rt.enqueue(ctx, ops, nil)
+	return err
+}
+
+// CommitIf перевіряє condKey і додає items у буфер під одним lock-ом (див. cache.TxDriver).
+func (rt *WriteBehindDriver) CommitIf(ctx context.Context, condKey, old []byte, items map[string][]byte, expiriesSecond int) (bool, error) {
+	ops := make(map[string]writeOp, len(items))
+	for key, val := range items {
+		ops[key] = newSetOp(val, expiriesSecond)
+	}
+	return rt.enqueue(ctx, ops, func() (bool, error) {
+		cur, exist, err := rt.getLocked(ctx, condKey)
+		if err != nil {
+			return false, err
+		}
+		return exist == (old != nil) && bytes.Equal(cur, old), nil
+	})
+}
+
+// SetIfNotExists додає запис у буфер, лише якщо ключа немає ні в буфері, ні в inner (див. cache.AtomicDriver).
+func (rt *WriteBehindDriver) SetIfNotExists(ctx context.Context, key, val []byte, expiriesSecond int) (bool, error) {
+	ops := map[string]writeOp{string(key): newSetOp(val, expiriesSecond)}
+	return rt.enqueue(ctx, ops, func() (bool, error) {
+		_, exist, err := rt.getLocked(ctx, key)
+		return !exist, err
+	})
+}
+
+// CompareAndSwap додає запис у буфер, лише якщо поточне значення ключа дорівнює old (див. cache.AtomicDriver).
+func (rt *WriteBehindDriver) CompareAndSwap(ctx context.Context, key, old, newVal []byte, expiriesSecond int) (bool, error) {
+	ops := map[string]writeOp{string(key): newSetOp(newVal, expiriesSecond)}
+	return rt.enqueue(ctx, ops, func() (bool, error) {
+		cur, exist, err := rt.getLocked(ctx, key)
+		return exist && bytes.Equal(cur, old), err
+	})
+}
+
+// Incr додає до лічильника delta під lock-ом буфера (див. cache.CounterDriver).
+// Наявний ключ зберігає свій TTL: з буфера або з inner, якщо inner реалізує TTLDriver;
+// інакше залишковий TTL невідомий, і ключ переписується з expiriesSecond.
+func (rt *WriteBehindDriver) Incr(ctx context.Context, key []byte, delta int64, expiriesSecond int) (int64, error) {
+	var n int64
+	ops := map[string]writeOp{string(key): {}}
+	_, err := rt.enqueue(ctx, ops, func() (bool, error) {
+		cur, expireAt, exist, err := rt.getExpireLocked(ctx, key, expiriesSecond)
+		if err != nil {
+			return false, err
+		}
+		n = 0
+		if exist {
+			if n, err = strconv.ParseInt(string(cur), 10, 64); err != nil {
+				return false, cache.ErrNotCounter
+			}
+		}
+		n += delta
+		ops[string(key)] = writeOp{val: strconv.AppendInt(nil, n, 10), expireAt: expireAt}
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// getExpireLocked читає ключ з буфера або inner разом із моментом завершення TTL (нульовий — без TTL).
+// Для відсутнього ключа, а також коли inner не повідомляє TTL, момент рахується від expiriesSecond.
+// Викликається під mu (див. getLocked).
+func (rt *WriteBehindDriver) getExpireLocked(ctx context.Context, key []byte, expiriesSecond int) ([]byte, time.Time, bool, error) {
+	if op, ok := rt.lookup(key); ok {
+		if val, exist := op.value(time.Now()); exist {
+			return val, op.expireAt, true, ctx.Err()
+		}
+		return nil, expireAt(expiriesSecond), false, ctx.Err()
+	}
+	if td, ok := rt.inner.(TTLDriver); ok {
+		val, ttl, exist, err := td.GetWithTTL(ctx, key)
+		if err != nil || !exist {
+			return nil, expireAt(expiriesSecond), false, err
+		}
+		return val, expireAt(ttl), true, nil
+	}
+	val, exist, err := cache.DriverGet(ctx, rt.inner, key)
+	return val, expireAt(expiriesSecond), exist, err
+}
+
+// enqueue додає ops у буфер одним кроком. Якщо буфер повний, чекає на завершення скидання.
+// cond, якщо задана, перевіряється під mu після очікування; false — зміни не додаються.
+func (rt *WriteBehindDriver) enqueue(ctx context.Context, ops map[string]writeOp, cond func() (bool, error)) (bool, error) {
+	rt.mu.Lock()
+	for {
+		if rt.closed {
+			rt.mu.Unlock()
+			return false, ErrDriverClosed
+		}
+		if err := ctx.Err(); err != nil {
+			rt.mu.Unlock()
+			return false, err
+		}
+		if rt.fits(ops) {
+			break
+		}
+		drained := rt.drained
+		rt.mu.Unlock()
+		rt.kickFlush()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+		}
+		rt.mu.Lock()
+	}
+	if cond != nil {
+		if ok, err := cond(); err != nil || !ok {
+			rt.mu.Unlock()
+			return false, err
+		}
+	}
+	for key, op := range ops {
+		rt.pending[key] = op
+	}
+	full := len(rt.pending) >= rt.flushSize
+	rt.mu.Unlock()
+
+	if full {
+		rt.kickFlush()
+	}
+	return true, nil
+}
+
+// fits повідомляє, чи вміщуються ops у буфер. Пакет, більший за WithMaxPending, приймається
+// в порожній буфер. Викликається під mu.
+func (rt *WriteBehindDriver) fits(ops map[string]writeOp) bool {
+	n := len(rt.pending)
+	for key := range ops {
+		if _, ok := rt.pending[key]; !ok {
+			n++
+		}
+	}
+	return n <= rt.maxPending || len(rt.pending) == 0
+}
+
+func (rt *WriteBehindDriver) kickFlush() {
+	select {
+	case rt.kick <- struct{}{}:
+	default:
+	}
+}
+
+// loop скидає буфер за таймером і за сигналом kickFlush, доки драйвер не закрито.
+func (rt *WriteBehindDriver) loop() {
+	defer rt.wg.Done()
+
+	t := time.NewTicker(rt.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-rt.done:
+			return
+		case <-t.C:
+		case <-rt.kick:
+		}
+		if err := rt.flush(context.Background()); err != nil {
+			rt.mu.Lock()
+			rt.err = err
+			rt.mu.Unlock()
+		}
+	}
+}
+
+// Flush синхронно записує буфер у inner. Повертає також помилку попереднього фонового скидання.
+// Якщо ctx уже скасовано, буфер лишається незаписаним.
+func (rt *WriteBehindDriver) Flush(ctx context.Context) error {
+	err := rt.flush(ctx)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	err, rt.err = errors.Join(rt.err, err), nil
+	return err
+}
+
+// flush записує буфер у inner. Пакет уже вийнято з pending, тож він записується без скасування ctx:
+// перерваний запис втратив би зміни, які читання вже не знайдуть у буфері.
+func (rt *WriteBehindDriver) flush(ctx context.Context) error {
+	rt.flushMu.Lock()
+	defer rt.flushMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rt.mu.Lock()
+	batch := rt.pending
+	if len(batch) == 0 {
+		rt.mu.Unlock()
+		return nil
+	}
+	rt.pending = make(map[string]writeOp)
+	rt.flushing = batch
+	rt.mu.Unlock()
+
+	err := rt.write(context.WithoutCancel(ctx), batch)
+
+	rt.mu.Lock()
+	rt.flushing = nil
+	close(rt.drained)
+	rt.drained = make(chan struct{})
+	rt.mu.Unlock()
+	return err
+}
+
+// write записує пакет у inner: видалення одним DelMany, записи — SetMany на кожне значення TTL.
+// TTL скорочується на час перебування в буфері; записи, TTL яких уже минув, видаляються.
+func (rt *WriteBehindDriver) write(ctx context.Context, batch map[string]writeOp) error {
+	now := time.Now()
+	var dels [][]byte
+	sets := make(map[int]map[string][]byte)
+	for key, op := range batch {
+		if op.del || op.expired(now) {
+			dels = append(dels, []byte(key))
+			continue
+		}
+		ttl := 0
+		if !op.expireAt.IsZero() {
+			ttl = int(math.Ceil(op.expireAt.Sub(now).Seconds()))
+		}
+		if sets[ttl] == nil {
+			sets[ttl] = make(map[string][]byte)
+		}
+		sets[ttl][key] = op.val
+	}
+
+	if err := cache.DriverDelMany(ctx, rt.inner, dels); err != nil {
+		return err
+	}
+	for ttl, items := range sets {
+		if err := cache.DriverSetMany(ctx, rt.inner, items, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rt *WriteBehindDriver) Clear() error {
+	return rt.ClearCtx(context.Background())
+}
+
+// ClearCtx відкидає буфер і очищає inner. Скидання, що вже виконується, завершується до очищення.
+func (rt *WriteBehindDriver) ClearCtx(ctx context.Context) error {
+	rt.flushMu.Lock()
+	defer rt.flushMu.Unlock()
+
+	rt.mu.Lock()
+	rt.pending = make(map[string]writeOp)
+	close(rt.drained)
+	rt.drained = make(chan struct{})
+	rt.mu.Unlock()
+
+	return cache.DriverClear(ctx, rt.inner)
+}
+
+// Close зупиняє фонове скидання, записує залишок буфера і закриває inner.
+func (rt *WriteBehindDriver) Close() error {
+	rt.mu.Lock()
+	if rt.closed {
+		rt.mu.Unlock()
+		return ErrDriverClosed
+	}
+	rt.closed = true
+	rt.mu.Unlock()
+
+	close(rt.done)
+	rt.wg.Wait()
+	return errors.Join(rt.Flush(context.Background()), rt.inner.Close())
+}
+
+// Scan записує буфер і обходить inner (див. cache.IterableDriver).
+// Помилку попереднього фонового скидання Scan не забирає: її повертає Flush або Close.
+func (rt *WriteBehindDriver) Scan(ctx context.Context, prefix []byte, fn func(key, val []byte) bool) error {
+	id, ok := rt.inner.(cache.IterableDriver)
+	if !ok {
+		return cache.ErrNotIterable
+	}
+	if err := rt.flush(ctx); err != nil {
+		return err
+	}
+	return id.Scan(ctx, prefix, fn)
+}
+
+// DelPrefix записує буфер і видаляє ключі з префіксом в inner (див. cache.PrefixDeleter).
+// Як і Scan, не забирає помилку фонового скидання.
+func (rt *WriteBehindDriver) DelPrefix(ctx context.Context, prefix []byte) error {
+	if err := rt.flush(ctx); err != nil {
+		return err
+	}
+	return delPrefix(ctx, rt.inner, prefix)
+}
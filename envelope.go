@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// envelopeMagic — префікс, за яким Cache відрізняє конверт з метаданими від сирого значення.
+// Починається з 0x00, тож не збігається ні з msgpack-мапою (payload чанку), ні з текстом.
+var envelopeMagic = []byte{0x00, 0xCE, 'e', 'n', 'v', 0x01}
+
+// envelope — службова обгортка навколо значення користувача.
+//
+// Значення без метаданих зберігаються в драйвері як є (сирі байти),
+// а в конверт загортаються лише тоді, коли потрібні метадані
+// або коли сирі байти випадково починаються з envelopeMagic.
+type envelope struct {
+	// SoftExpire — момент (unix nano), після якого значення вважається застарілим
+	// і оновлюється у фоні (stale-while-revalidate). 0 — не застаріває.
+	SoftExpire int64 `msgpack:"se,omitempty"`
+
+	// Expire — момент (unix nano) логічного завершення TTL, а Delta — скільки наносекунд
+	// зайняло обчислення значення. Використовуються XFetch (див. WithXFetch);
+	// Expire без Delta також зберігає TTL лічильника запасного Incr.
+	Expire int64 `msgpack:"ex,omitempty"`
+	Delta  int64 `msgpack:"dt,omitempty"`
+
+	// Tags — версії тегів на момент запису (див. SetWithTags). Запис дійсний, доки всі версії актуальні.
+	Tags map[string]uint64 `msgpack:"tg,omitempty"`
+	// Deps — версії чанків, від яких залежить значення (див. SetDependent).
+	Deps map[string]uint64 `msgpack:"dp,omitempty"`
+
+	// Tombstone — "надгробок": значення не існує (див. ErrNotFoundCacheable), Value порожнє.
+	Tombstone bool `msgpack:"nf,omitempty"`
+
+	Value []byte `msgpack:"v"`
+}
+
+// hasMeta повідомляє, чи містить конверт щось, крім значення.
+func (env *envelope) hasMeta() bool {
+	return env.SoftExpire != 0 || env.Expire != 0 || env.Tombstone || env.hasDeps()
+}
+
+// hasDeps повідомляє, чи залежить дійсність запису від тегів або чанків.
+func (env *envelope) hasDeps() bool {
+	return len(env.Tags) > 0 || len(env.Deps) > 0
+}
+
+// encodeEntry серіалізує конверт для запису в драйвер.
+func encodeEntry(env envelope) ([]byte, error) {
+	if !env.hasMeta() && !bytes.HasPrefix(env.Value, envelopeMagic) {
+		return env.Value, nil
+	}
+
+	var buffer bytes.Buffer
+	buffer.Write(envelopeMagic)
+	if err := msgpack.NewEncoder(&buffer).Encode(&env); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decodeEntry розбирає байти з драйвера: конверт або сире значення.
+func decodeEntry(raw []byte) (envelope, error) {
+	if !bytes.HasPrefix(raw, envelopeMagic) {
+		return envelope{Value: raw}, nil
+	}
+
+	var env envelope
+	if err := msgpack.Unmarshal(raw[len(envelopeMagic):], &env); err != nil {
+		return envelope{}, fmt.Errorf("invalid cache envelope: %w", err)
+	}
+	return env, nil
+}
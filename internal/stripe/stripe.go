@@ -0,0 +1,86 @@
+// Package stripe містить смугові блокування, спільні для пакетів cache і drivers.
+package stripe
+
+import (
+	"hash/fnv"
+	"slices"
+	"sync"
+)
+
+// Lock — фіксований набір мʼютексів, між якими ключі розподіляються за хешем.
+// Дає атомарність read-modify-write для одного ключа в межах процесу
+// без окремого мʼютекса на кожен ключ.
+type Lock [stripes]sync.Mutex
+
+// Get повертає мʼютекс смуги ключа.
+func (l *Lock) Get(key []byte) *sync.Mutex {
+	return &l[index(key)]
+}
+
+// LockAll блокує смуги всіх ключів у порядку зростання індексу (щоб уникнути взаємоблокувань)
+// і повертає функцію розблокування.
+func (l *Lock) LockAll(keys [][]byte) (unlock func()) {
+	idx := indexes(keys)
+	for _, i := range idx {
+		l[i].Lock()
+	}
+	return func() {
+		for _, i := range idx {
+			l[i].Unlock()
+		}
+	}
+}
+
+// RWLock — Lock з RWMutex: читання різних викликачів не блокують одне одного,
+// а запис ключа чекає, доки завершаться читання його смуги.
+type RWLock [stripes]sync.RWMutex
+
+// Get повертає RWMutex смуги ключа.
+func (l *RWLock) Get(key []byte) *sync.RWMutex {
+	return &l[index(key)]
+}
+
+// LockAll блокує смуги всіх ключів на запис (див. Lock.LockAll).
+func (l *RWLock) LockAll(keys [][]byte) (unlock func()) {
+	idx := indexes(keys)
+	for _, i := range idx {
+		l[i].Lock()
+	}
+	return func() {
+		for _, i := range idx {
+			l[i].Unlock()
+		}
+	}
+}
+
+// RLockAll блокує смуги всіх ключів на читання у порядку зростання індексу.
+func (l *RWLock) RLockAll(keys [][]byte) (unlock func()) {
+	idx := indexes(keys)
+	for _, i := range idx {
+		l[i].RLock()
+	}
+	return func() {
+		for _, i := range idx {
+			l[i].RUnlock()
+		}
+	}
+}
+
+// stripes — кількість смуг Lock і RWLock.
+const stripes = 64
+
+func index(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % stripes)
+}
+
+// indexes повертає відсортовані індекси смуг ключів без повторів.
+func indexes(keys [][]byte) []int {
+	idx := make([]int, 0, len(keys))
+	for _, key := range keys {
+		idx = append(idx, index(key))
+	}
+	slices.Sort(idx)
+	return slices.Compact(idx)
+}
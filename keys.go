@@ -0,0 +1,116 @@
+package cache
+
+import "encoding/binary"
+
+// Службові ключі Cache (чанки тощо) живуть у зарезервованому просторі: перший байт — reservedKeyMarker,
+// другий — версія формату ключів keyLayoutVersion, далі — вид ключа і імʼя з префіксом довжини.
+// Імена з префіксом довжини не можуть "перетекти" одне в одне (на відміну від склеювання з суфіксом),
+// а ключі користувача, що починаються з reservedKeyMarker, екрануються (див. userKey).
+const (
+	reservedKeyMarker = 0x00
+	keyLayoutVersion  = 0x01
+)
+
+// Види службових ключів.
+const (
+	keyKindChunk     = 'c'
+	keyKindNamespace = 'n'
+	keyKindTag       = 't'
+)
+
+// Частини чанку (див. chunkKey).
+const (
+	chunkPartPayload = 'p'
+	chunkPartVersion = 'v'
+	chunkPartBase    = 'b'
+	chunkPartDelta   = 'd'
+	chunkPartPages   = 'm'
+	chunkPartPage    = 'g'
+)
+
+// userKey переводить ключ користувача у ключ сховища.
+//
+// Ключі, що не починаються з reservedKeyMarker, не змінюються. До решти дописується ще один
+// reservedKeyMarker: другий байт такого ключа — 0x00, а не версія формату, тож він не перетинається
+// зі службовими ключами, і відображення лишається взаємно однозначним.
+func userKey(key []byte) []byte {
+	if len(key) == 0 || key[0] != reservedKeyMarker {
+		return key
+	}
+	k := make([]byte, 0, len(key)+1)
+	k = append(k, reservedKeyMarker)
+	return append(k, key...)
+}
+
+// fromUserKey — обернене до userKey перетворення.
+func fromUserKey(key []byte) []byte {
+	if len(key) > 1 && key[0] == reservedKeyMarker && key[1] == reservedKeyMarker {
+		return key[1:]
+	}
+	return key
+}
+
+// internalKey будує службовий ключ: marker, версія формату, вид, uvarint(len(name)), name, part.
+func internalKey(kind byte, name string, part byte) []byte {
+	k := make([]byte, 0, 3+binary.MaxVarintLen64+len(name)+1+8)
+	k = append(k, reservedKeyMarker, keyLayoutVersion, kind)
+	k = binary.AppendUvarint(k, uint64(len(name)))
+	k = append(k, name...)
+	return append(k, part)
+}
+
+// chunkKey — ключ частини part чанку name.
+func chunkKey(name string, part byte) []byte {
+	return internalKey(keyKindChunk, name, part)
+}
+
+// chunkKeyN — ключ нумерованої частини чанку (запис журналу, сторінка).
+// Номер кодується big-endian, тож ключі однієї частини впорядковані за номером.
+func chunkKeyN(name string, part byte, n uint64) []byte {
+	return binary.BigEndian.AppendUint64(chunkKey(name, part), n)
+}
+
+// namespacePrefix — префікс ключів простору імен name (див. Cache.Namespace).
+func namespacePrefix(name string) []byte {
+	return internalKey(keyKindNamespace, name, ':')
+}
+
+// storageKey дописує до ключа сховища префікс простору імен Cache — саме з ним ключ лежить у драйвері.
+// Префікс застосовується лише там, де ключ передається драйверу.
+func (ch *Cache) storageKey(key []byte) []byte {
+	if len(ch.prefix) == 0 {
+		return key
+	}
+	k := make([]byte, 0, len(ch.prefix)+len(key))
+	k = append(k, ch.prefix...)
+	return append(k, key...)
+}
+
+// storageKeys застосовує storageKey до кожного ключа.
+func (ch *Cache) storageKeys(keys [][]byte) [][]byte {
+	if len(ch.prefix) == 0 {
+		return keys
+	}
+	out := make([][]byte, len(keys))
+	for i, key := range keys {
+		out[i] = ch.storageKey(key)
+	}
+	return out
+}
+
+// storageItems застосовує storageKey до ключів items.
+func (ch *Cache) storageItems(items map[string][]byte) map[string][]byte {
+	if len(ch.prefix) == 0 {
+		return items
+	}
+	out := make(map[string][]byte, len(items))
+	for key, val := range items {
+		out[string(ch.prefix)+key] = val
+	}
+	return out
+}
+
+// isInternalKey повідомляє, чи належить ключ сховища до службового простору Cache.
+func isInternalKey(key []byte) bool {
+	return len(key) > 1 && key[0] == reservedKeyMarker && key[1] != reservedKeyMarker
+}
@@ -0,0 +1,119 @@
+package cache_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestChunkKeysDoNotCollide(t *testing.T) {
+	c := newFreeCache()
+
+	a, err := c.Chunk("a", 60)
+	if err != nil {
+		t.Fatalf("Chunk(a): %v", err)
+	}
+	a.SetRaw([]byte("k"), []byte("A"))
+	if err := a.SaveChanges(); err != nil {
+		t.Fatalf("a.SaveChanges(): %v", err)
+	}
+
+	// у старому форматі payload цього чанку лежав би на місці versionKey чанку "a"
+	av, err := c.Chunk("a_version", 60)
+	if err != nil {
+		t.Fatalf("Chunk(a_version): %v", err)
+	}
+	av.SetRaw([]byte("k"), []byte("AV"))
+	if err := av.SaveChanges(); err != nil {
+		t.Fatalf("a_version.SaveChanges(): %v", err)
+	}
+
+	// ключі користувача не перетинаються зі службовими
+	if err := c.Set([]byte("cache_package_chank_a"), []byte("user"), 60); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+
+	expectRaw(t, c, "a", "k", "A")
+	expectRaw(t, c, "a_version", "k", "AV")
+}
+
+func TestUserKeysWithReservedPrefix(t *testing.T) {
+	c := newFreeCache()
+	keys := [][]byte{{0x00}, {0x00, 0x00, 'x'}, {0x00, 0x01, 'c'}, []byte("plain")}
+
+	for i, key := range keys {
+		if err := c.Set(key, []byte{byte(i)}, 60); err != nil {
+			t.Fatalf("Set(%q): %v", key, err)
+		}
+	}
+	for i, key := range keys {
+		val, exist, err := c.Get(key)
+		if err != nil || !exist || !bytes.Equal(val, []byte{byte(i)}) {
+			t.Fatalf("Get(%q): val=%v exist=%v err=%v", key, val, exist, err)
+		}
+	}
+
+	vals, err := c.GetMany(keys)
+	if err != nil {
+		t.Fatalf("GetMany(): %v", err)
+	}
+	for i, key := range keys {
+		if !bytes.Equal(vals[string(key)], []byte{byte(i)}) {
+			t.Fatalf("GetMany(%q): got %v", key, vals[string(key)])
+		}
+	}
+
+	if err := c.Del(keys[1]); err != nil {
+		t.Fatalf("Del(): %v", err)
+	}
+	if _, exist, _ := c.Get(keys[1]); exist {
+		t.Fatalf("Del(%q): key still exists", keys[1])
+	}
+	if _, exist, _ := c.Get(keys[0]); !exist {
+		t.Fatalf("Del(%q) removed %q", keys[1], keys[0])
+	}
+}
+
+func TestMigrateLegacyChunks(t *testing.T) {
+	c := newFreeCache()
+
+	// чанк "old", записаний у старому форматі ключів
+	payload, err := msgpack.Marshal(cache.ChunkRaw{Version: 3, Data: map[string][]byte{"k": []byte("v")}})
+	if err != nil {
+		t.Fatalf("Marshal(): %v", err)
+	}
+	if err := c.Set([]byte("cache_package_chank_old"), payload, 60); err != nil {
+		t.Fatalf("Set(payload): %v", err)
+	}
+	if err := c.Set([]byte("cache_package_chank_old_version"), binary.LittleEndian.AppendUint64(nil, 3), 60); err != nil {
+		t.Fatalf("Set(version): %v", err)
+	}
+
+	n, err := c.MigrateLegacyChunks(60, "old", "missing")
+	if err != nil || n != 1 {
+		t.Fatalf("MigrateLegacyChunks(): n=%d err=%v", n, err)
+	}
+	expectRaw(t, c, "old", "k", "v")
+	if _, exist, _ := c.Get([]byte("cache_package_chank_old")); exist {
+		t.Fatalf("legacy payload was not removed")
+	}
+
+	// версія збереглась: наступний коміт продовжує з неї
+	ch, err := c.Chunk("old", 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	ch.SetRaw([]byte("k2"), []byte("v2"))
+	if err := ch.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	if n, err := c.MigrateLegacyChunks(60, "old"); err != nil || n != 0 {
+		t.Fatalf("second MigrateLegacyChunks(): n=%d err=%v", n, err)
+	}
+	expectRaw(t, c, "old", "k2", "v2")
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrNotFound повертає завантажувач (Loader, OnSet), якщо значення не існує.
+	// Get з зареєстрованим завантажувачем повідомляє такий ключ як відсутній, нічого не зберігаючи.
+	ErrNotFound = errors.New("значення не існує")
+
+	// ErrNotFoundCacheable — ErrNotFound, який варто закешувати: OnSet (і Get із завантажувачем)
+	// зберігає надгробок з окремим TTL (див. WithNegativeTTL) і до його завершення повідомляє ключ
+	// як відсутній, не викликаючи завантажувач. errors.Is(ErrNotFoundCacheable, ErrNotFound) == true.
+	ErrNotFoundCacheable = fmt.Errorf("%w (кешується)", ErrNotFound)
+)
+
+// Loader завантажує значення ключа key для Get (див. RegisterLoader).
+type Loader func(ctx context.Context, key []byte) (value []byte, err error)
+
+// registeredLoader — завантажувач для ключів з префіксом prefix.
+type registeredLoader struct {
+	prefix         string
+	fn             Loader
+	expiriesSecond int
+}
+
+// RegisterLoader реєструє завантажувач для ключів з префіксом prefix (порожній — усі ключі).
+//
+// Get відсутнього ключа викликає завантажувач з найдовшим відповідним префіксом, зберігає результат
+// з TTL expiriesSecond і повертає його — так само, як OnSet (single-flight, WithStaleWhileRevalidate,
+// WithXFetch). Якщо завантажувач повертає ErrNotFound, Get повертає exist=false; ErrNotFoundCacheable
+// до того ж кешує відсутність значення.
+// Інші методи читання (GetMany, GetAndDel, Scan) завантажувачі не викликають.
+//
+// Повторна реєстрація того самого префікса замінює завантажувач. Кожен простір імен (див. Namespace)
+// має власний реєстр, спільний для всіх його викликачів.
+func (ch *Cache) RegisterLoader(prefix string, fn Loader, expiriesSecond int) {
+	ch.loadersMu.Lock()
+	defer ch.loadersMu.Unlock()
+
+	ch.loaders = slices.DeleteFunc(ch.loaders, func(l registeredLoader) bool {
+		return l.prefix == prefix
+	})
+	ch.loaders = append(ch.loaders, registeredLoader{prefix: prefix, fn: fn, expiriesSecond: expiriesSecond})
+	slices.SortStableFunc(ch.loaders, func(a, b registeredLoader) int {
+		return len(b.prefix) - len(a.prefix)
+	})
+}
+
+// loaderFor повертає завантажувач з найдовшим префіксом, що відповідає key.
+func (ch *Cache) loaderFor(key []byte) (registeredLoader, bool) {
+	ch.loadersMu.RLock()
+	defer ch.loadersMu.RUnlock()
+
+	for _, l := range ch.loaders {
+		if strings.HasPrefix(string(key), l.prefix) {
+			return l, true
+		}
+	}
+	return registeredLoader{}, false
+}
+
+// getLoaded — Get ключа із зареєстрованим завантажувачем l.
+func (ch *Cache) getLoaded(ctx context.Context, key []byte, l registeredLoader) ([]byte, bool, error) {
+	key = bytes.Clone(key)
+	val, err := ch.OnSetCtx(ctx, key, func(ctx context.Context) ([]byte, error) {
+		return l.fn(ctx, key)
+	}, l.expiriesSecond)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
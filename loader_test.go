@@ -0,0 +1,67 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestRegisterLoader(t *testing.T) {
+	c := newFreeCache()
+
+	var userCalls, adminCalls atomic.Int64
+	c.RegisterLoader("user:", func(ctx context.Context, key []byte) ([]byte, error) {
+		userCalls.Add(1)
+		switch string(key) {
+		case "user:missing":
+			return nil, cache.ErrNotFound
+		case "user:broken":
+			return nil, errors.New("db is down")
+		}
+		return append([]byte("loaded "), key...), nil
+	}, 60)
+	c.RegisterLoader("user:admin:", func(ctx context.Context, key []byte) ([]byte, error) {
+		adminCalls.Add(1)
+		return []byte("admin"), nil
+	}, 60)
+
+	for i := 0; i < 3; i++ {
+		val, exist, err := c.Get([]byte("user:1"))
+		if err != nil || !exist || string(val) != "loaded user:1" {
+			t.Fatalf("Get(user:1) = %q, %v, %v", val, exist, err)
+		}
+	}
+	if n := userCalls.Load(); n != 1 {
+		t.Fatalf("expected loader to run once, got %d", n)
+	}
+
+	// найдовший префікс перемагає
+	if val, _, err := c.Get([]byte("user:admin:7")); err != nil || string(val) != "admin" {
+		t.Fatalf("Get(user:admin:7) = %q, %v", val, err)
+	}
+	if adminCalls.Load() != 1 || userCalls.Load() != 1 {
+		t.Fatalf("unexpected loader calls: user=%d admin=%d", userCalls.Load(), adminCalls.Load())
+	}
+
+	// ErrNotFound — відсутній ключ, інші помилки повертаються викликачу
+	if val, exist, err := c.Get([]byte("user:missing")); err != nil || exist {
+		t.Fatalf("Get(user:missing) = %q, %v, %v; want not found", val, exist, err)
+	}
+	if _, _, err := c.Get([]byte("user:broken")); err == nil {
+		t.Fatal("Get(user:broken): expected loader error")
+	}
+
+	// ключі без завантажувача і значення, записані напряму, працюють як раніше
+	if _, exist, err := c.Get([]byte("order:1")); err != nil || exist {
+		t.Fatalf("Get(order:1) = %v, %v; want plain miss", exist, err)
+	}
+	if err := c.Set([]byte("user:2"), []byte("stored"), 60); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if val, _, _ := c.Get([]byte("user:2")); string(val) != "stored" {
+		t.Fatalf("Get(user:2) = %q, want stored value", val)
+	}
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+)
+
+// legacyChunkKey — ключ чанку у форматі до keyLayoutVersion: "cache_package_chank_" + name + suffix.
+// У цьому форматі ключі чанків могли збігатися між собою ("a_version" і версія "a") і з ключами користувача.
+func legacyChunkKey(name, suffix string) []byte {
+	return []byte("cache_package_chank_" + name + suffix)
+}
+
+// MigrateLegacyChunks переносить чанки names зі старого формату ключів ("cache_package_chank_<name>...")
+// у зарезервований простір службових ключів і повертає кількість перенесених чанків.
+//
+// Переносяться payload, versionKey і службові ключі WithDeltaLog/WithPages; нові ключі отримують
+// TTL expiriesSecond (TTL старих ключів драйвер не повідомляє). Старі ключі видаляються після
+// успішного запису нових. Чанк, якого немає у старому форматі або який уже існує в новому,
+// пропускається, тож повторний запуск безпечний.
+//
+// Міграція одноразова: на час її виконання не повинно бути writer-ів, які ще пишуть у старому форматі.
+// Через колізії старого формату чанк "a" і чанк "a_version" перенести разом коректно неможливо.
+func (ch *Cache) MigrateLegacyChunks(expiriesSecond int, names ...string) (int, error) {
+	return ch.MigrateLegacyChunksCtx(context.Background(), expiriesSecond, names...)
+}
+
+func (ch *Cache) MigrateLegacyChunksCtx(ctx context.Context, expiriesSecond int, names ...string) (int, error) {
+	migrated := 0
+	for _, name := range names {
+		ok, err := ch.migrateLegacyChunk(ctx, name, expiriesSecond)
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated++
+		}
+	}
+	return migrated, nil
+}
+
+func (ch *Cache) migrateLegacyChunk(ctx context.Context, name string, expiriesSecond int) (bool, error) {
+	oldKeys := map[string][]byte{
+		string(legacyChunkKey(name, "")):         getChunkKey(name),
+		string(legacyChunkKey(name, "_version")): getChunkVersionKey(name),
+		string(legacyChunkKey(name, "_base")):    getChunkBaseKey(name),
+		string(legacyChunkKey(name, "_pages")):   getChunkPagesKey(name),
+	}
+	keys := make([][]byte, 0, len(oldKeys))
+	for key := range oldKeys {
+		keys = append(keys, []byte(key))
+	}
+	vals, err := ch.getMany(ctx, keys)
+	if err != nil || len(vals) == 0 {
+		return false, err
+	}
+
+	items := make(map[string][]byte, len(vals))
+	for key, val := range vals {
+		items[string(oldKeys[key])] = val
+	}
+
+	payload, payloadExist := vals[string(legacyChunkKey(name, ""))]
+	verRaw, verExist := vals[string(legacyChunkKey(name, "_version"))]
+	var ver uint64
+	if verExist {
+		if ver, err = decodeVersion(verRaw); err != nil {
+			return false, err
+		}
+	}
+
+	// нумеровані частини: сторінки (за маніфестом) або записи журналу між базою і versionKey
+	numbered := make(map[string][]byte)
+	if manifest, ok := vals[string(legacyChunkKey(name, "_pages"))]; ok {
+		manifestVer, meta, err := decodePageManifest(manifest)
+		if err != nil {
+			return false, err
+		}
+		ver, verExist = manifestVer, true
+		for i, m := range meta {
+			if m.version > 0 {
+				numbered[string(legacyChunkKey(name, "_page_"+strconv.Itoa(i)))] = getChunkPageKey(name, i)
+			}
+		}
+	} else if payloadExist {
+		base, err := decodeChunkRaw(payload, true, CodecMsgpack)
+		if err != nil {
+			return false, err
+		}
+		if !verExist {
+			ver, verExist = base.Version, true
+		}
+		for v := base.Version + 1; v <= ver; v++ {
+			numbered[string(legacyChunkKey(name, "_delta_"+strconv.FormatUint(v, 10)))] = getChunkDeltaKey(name, v)
+		}
+	}
+	if !verExist {
+		return false, ErrChunkCorrupted
+	}
+	items[string(getChunkVersionKey(name))] = encodeVersion(ver)
+
+	if len(numbered) > 0 {
+		keys := make([][]byte, 0, len(numbered))
+		for key := range numbered {
+			keys = append(keys, []byte(key))
+		}
+		parts, err := ch.getMany(ctx, keys)
+		if err != nil {
+			return false, err
+		}
+		for key, val := range parts {
+			items[string(numbered[key])] = val
+		}
+		for key := range numbered {
+			oldKeys[key] = nil
+		}
+	}
+
+	// новий versionKey має бути відсутнім: інакше чанк уже живе в новому форматі
+	ok, err := ch.commitIf(ctx, getChunkVersionKey(name), nil, items, expiriesSecond)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	del := make([][]byte, 0, len(oldKeys))
+	for key := range oldKeys {
+		del = append(del, []byte(key))
+	}
+	return true, ch.delMany(ctx, del)
+}
@@ -0,0 +1,29 @@
+package cache
+
+// Namespace повертає простір імен name — вигляд того самого драйвера, у якому всі ключі,
+// чанки і вкладені простори непомітно для викликача отримують власний префікс.
+//
+// Простори з різними іменами (і кореневий кеш) не бачать ключів один одного: Scan і DelPrefix
+// обходять лише свій простір, Clear видаляє лише його ключі (див. ClearCtx), а Close
+// нічого не робить — драйвер закриває кореневий кеш. Опції кешу успадковуються всі,
+// а запасні lock-и SetNX, CAS, Incr і комітів чанків — спільні з кореневим кешем.
+// Close кореневого кешу чекає і на фонові оновлення просторів.
+//
+// Виклики з тим самим name повертають той самий *Cache, тож single-flight, дедуплікація фонових
+// оновлень, завантажувачі (див. RegisterLoader) і статистика спільні для всіх викликачів простору.
+// Створений простір живе, доки живе батьківський кеш: імена просторів не варто брати з
+// необмеженої множини (наприклад, id запиту).
+func (ch *Cache) Namespace(name string) *Cache {
+	if ns, ok := ch.namespaces.Load(name); ok {
+		return ns.(*Cache)
+	}
+	prefix := append(append([]byte(nil), ch.prefix...), namespacePrefix(name)...)
+	ns, _ := ch.namespaces.LoadOrStore(name, &Cache{
+		dr:           ch.dr,
+		cacheOptions: ch.cacheOptions,
+		bg:           ch.bg,
+		locks:        ch.locks,
+		prefix:       prefix,
+	})
+	return ns.(*Cache)
+}
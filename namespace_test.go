@@ -0,0 +1,173 @@
+package cache_test
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestNamespaceFreeCache(t *testing.T) {
+	testNamespace(t, newFreeCache())
+}
+
+func TestNamespaceBadgerDB(t *testing.T) {
+	testNamespace(t, newBadgerCache(t))
+}
+
+func testNamespace(t *testing.T, root *cache.Cache) {
+	teamA, teamB := root.Namespace("team-a"), root.Namespace("team-b")
+	nested := teamA.Namespace("jobs")
+
+	for name, c := range map[string]*cache.Cache{"root": root, "team-a": teamA, "team-b": teamB, "nested": nested} {
+		if err := c.Set([]byte("key"), []byte(name), 60); err != nil {
+			t.Fatalf("%s: Set(): %v", name, err)
+		}
+		chunk, err := c.Chunk("shared", 60)
+		if err != nil {
+			t.Fatalf("%s: Chunk(): %v", name, err)
+		}
+		chunk.SetRaw([]byte("owner"), []byte(name))
+		if err := chunk.SaveChanges(); err != nil {
+			t.Fatalf("%s: SaveChanges(): %v", name, err)
+		}
+	}
+
+	expect := func(c *cache.Cache, name, want string) {
+		t.Helper()
+		val, exist, err := c.Get([]byte("key"))
+		if err != nil {
+			t.Fatalf("%s: Get(): %v", name, err)
+		}
+		if want == "" {
+			if exist {
+				t.Fatalf("%s: expected key to be cleared, got %q", name, val)
+			}
+		} else if !exist || string(val) != want {
+			t.Fatalf("%s: Get() = %q, %v; want %q", name, val, exist, want)
+		}
+
+		chunk, err := c.Chunk("shared", 60)
+		if err != nil {
+			t.Fatalf("%s: Chunk(): %v", name, err)
+		}
+		owner, ok := chunk.GetRaw([]byte("owner"))
+		if want == "" {
+			if ok {
+				t.Fatalf("%s: expected chunk to be cleared, got %q", name, owner)
+			}
+		} else if !ok || string(owner) != want {
+			t.Fatalf("%s: chunk owner = %q, %v; want %q", name, owner, ok, want)
+		}
+	}
+	expect(root, "root", "root")
+	expect(teamA, "team-a", "team-a")
+	expect(teamB, "team-b", "team-b")
+	expect(nested, "nested", "nested")
+
+	// ключі просторів не видно ні з кореня, ні з сусіднього простору
+	var keys []string
+	if err := teamB.Scan(nil, func(key, _ []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}); err != nil {
+		t.Fatalf("Scan(): %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "key" {
+		t.Fatalf("team-b: Scan() = %q, want only its own key", keys)
+	}
+
+	// Clear простору видаляє його ключі, чанки і вкладені простори, але не чужі
+	if err := teamA.Clear(); err != nil {
+		t.Fatalf("team-a: Clear(): %v", err)
+	}
+	expect(teamA, "team-a", "")
+	expect(nested, "nested", "")
+	expect(root, "root", "root")
+	expect(teamB, "team-b", "team-b")
+
+	if err := teamB.Close(); err != nil {
+		t.Fatalf("team-b: Close(): %v", err)
+	}
+	expect(root, "root", "root")
+}
+
+// yieldingDriver — драйвер без опціональних інтерфейсів, який віддає процесор перед кожним записом,
+// щоб read-modify-write без lock-а гарантовано перемежовувались.
+type yieldingDriver struct {
+	cache.CacheDriver
+}
+
+func (d yieldingDriver) Set(key, val []byte, expiriesSecond int) error {
+	runtime.Gosched()
+	return d.CacheDriver.Set(key, val, expiriesSecond)
+}
+
+// Запасна атомарність (драйвер без AtomicDriver/CounterDriver) діє між різними виглядами простору.
+func TestNamespaceSharedLocks(t *testing.T) {
+	const (
+		workers = 16
+		perWork = 50
+	)
+	root := cache.NewCache(yieldingDriver{newFreeCacheDriver()})
+
+	runConcurrently(workers, func(int) {
+		ns := root.Namespace("shared")
+		for i := 0; i < perWork; i++ {
+			if _, err := ns.Incr([]byte("n"), 1, 60); err != nil {
+				t.Errorf("Incr(): %v", err)
+				return
+			}
+		}
+	})
+	if n, err := root.Namespace("shared").Incr([]byte("n"), 0, 60); err != nil || n != workers*perWork {
+		t.Fatalf("Incr() across views = %d, %v, want %d (lost updates)", n, err, workers*perWork)
+	}
+
+	var won atomic.Int64
+	runConcurrently(workers, func(int) {
+		ok, err := root.Namespace("shared").SetNX([]byte("once"), []byte("x"), 60)
+		if err != nil {
+			t.Errorf("SetNX(): %v", err)
+		}
+		if ok {
+			won.Add(1)
+		}
+	})
+	if won.Load() != 1 {
+		t.Fatalf("SetNX() across views: %d winners, want 1", won.Load())
+	}
+}
+
+// Повторні виклики Namespace з тим самим імʼям ділять single-flight і завантажувачі.
+func TestNamespaceSharedState(t *testing.T) {
+	root := newFreeCache()
+
+	var calls atomic.Int64
+	runConcurrently(8, func(int) {
+		_, err := root.Namespace("team").OnSet([]byte("k"), func() ([]byte, error) {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			return []byte("v"), nil
+		}, 60)
+		if err != nil {
+			t.Errorf("OnSet(): %v", err)
+		}
+	})
+	if calls.Load() != 1 {
+		t.Fatalf("OnSet() across Namespace calls: loader ran %d times, want 1", calls.Load())
+	}
+
+	root.Namespace("team").RegisterLoader("user:", func(ctx context.Context, key []byte) ([]byte, error) {
+		return []byte("loaded"), nil
+	}, 60)
+	if val, exist, err := root.Namespace("team").Get([]byte("user:1")); err != nil || !exist || string(val) != "loaded" {
+		t.Fatalf("Get() = %q, %v, %v; want loader registered via another Namespace call", val, exist, err)
+	}
+	if _, exist, err := root.Namespace("other").Get([]byte("user:1")); err != nil || exist {
+		t.Fatalf("Get() in another namespace = %v, %v; want no loader", exist, err)
+	}
+}
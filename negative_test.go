@@ -0,0 +1,108 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestNegativeCachingFreeCache(t *testing.T) {
+	testNegativeCaching(t, newFreeCache(cache.WithNegativeTTL(1)))
+}
+
+func TestNegativeCachingBadgerDB(t *testing.T) {
+	testNegativeCaching(t, newBadgerCache(t, cache.WithNegativeTTL(1)))
+}
+
+func testNegativeCaching(t *testing.T, c *cache.Cache) {
+	calls := 0
+	missing := func() ([]byte, error) {
+		calls++
+		return nil, cache.ErrNotFoundCacheable
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.OnSet([]byte("user:404"), missing, 60); !errors.Is(err, cache.ErrNotFound) {
+			t.Fatalf("OnSet(): expected ErrNotFound, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected loader to run once, got %d", calls)
+	}
+
+	// надгробок не видно як значення
+	if val, exist, err := c.Get([]byte("user:404")); err != nil || exist {
+		t.Fatalf("Get() = %q, %v, %v; want not found", val, exist, err)
+	}
+	if vals, err := c.GetMany([][]byte{[]byte("user:404")}); err != nil || len(vals) != 0 {
+		t.Fatalf("GetMany() = %q, %v; want empty", vals, err)
+	}
+
+	// простір імен успадковує WithNegativeTTL
+	ns := c.Namespace("ns")
+	if _, err := ns.OnSet([]byte("user:404"), missing, 60); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("Namespace OnSet(): expected ErrNotFound, got %v", err)
+	}
+
+	// надгробок живе WithNegativeTTL, а не TTL значення
+	time.Sleep(2 * time.Second)
+	for _, view := range []*cache.Cache{c, ns} {
+		val, err := view.OnSet([]byte("user:404"), func() ([]byte, error) {
+			calls++
+			return []byte("created"), nil
+		}, 60)
+		if err != nil || string(val) != "created" {
+			t.Fatalf("OnSet() after negative TTL = %q, %v", val, err)
+		}
+	}
+	if calls != 4 {
+		t.Fatalf("expected loader to run after negative TTL, got %d calls", calls)
+	}
+
+	// Get із завантажувачем теж кешує відсутність
+	loads := 0
+	c.RegisterLoader("order:", func(ctx context.Context, key []byte) ([]byte, error) {
+		loads++
+		return nil, cache.ErrNotFoundCacheable
+	}, 60)
+	for i := 0; i < 3; i++ {
+		if _, exist, err := c.Get([]byte("order:1")); err != nil || exist {
+			t.Fatalf("Get(order:1) = %v, %v; want not found", exist, err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected registered loader to run once, got %d", loads)
+	}
+}
+
+// SetNX вважає надгробок відсутнім значенням, а CAS не замінює його.
+func TestNegativeSetNX(t *testing.T) {
+	for name, c := range map[string]*cache.Cache{
+		"freecache": newFreeCache(),
+		"badger":    newBadgerCache(t),
+		"fallback":  cache.NewCache(plainDriver{newFreeCacheDriver()}),
+	} {
+		key := []byte("nx:404")
+		if _, err := c.OnSet(key, func() ([]byte, error) {
+			return nil, cache.ErrNotFoundCacheable
+		}, 60); !errors.Is(err, cache.ErrNotFound) {
+			t.Fatalf("%s: OnSet(): expected ErrNotFound, got %v", name, err)
+		}
+
+		if ok, err := c.CAS(key, nil, []byte("v"), 60); err != nil || ok {
+			t.Fatalf("%s: CAS() over tombstone = %v, %v; want false", name, ok, err)
+		}
+		if ok, err := c.SetNX(key, []byte("v"), 60); err != nil || !ok {
+			t.Fatalf("%s: SetNX() over tombstone = %v, %v; want true", name, ok, err)
+		}
+		if val, exist, err := c.Get(key); err != nil || !exist || string(val) != "v" {
+			t.Fatalf("%s: Get() = %q, %v, %v; want v", name, val, exist, err)
+		}
+		if ok, err := c.SetNX(key, []byte("other"), 60); err != nil || ok {
+			t.Fatalf("%s: SetNX() over value = %v, %v; want false", name, ok, err)
+		}
+	}
+}
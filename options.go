@@ -0,0 +1,76 @@
+package cache
+
+import "time"
+
+// Option налаштовує Cache під час створення (див. NewCache).
+type Option func(*Cache)
+
+// WithoutSingleFlight вимикає обʼєднання конкурентних промахів у OnSet.
+// За замовчуванням конкурентні OnSet для одного ключа викликають завантажувач лише один раз,
+// а решта викликачів отримують його результат (або помилку).
+func WithoutSingleFlight() Option {
+	return func(ch *Cache) {
+		ch.noSingleFlight = true
+	}
+}
+
+// WithStaleWhileRevalidate вмикає режим stale-while-revalidate для OnSet.
+//
+// Значення, завантажене через OnSet з expiriesSecond > 0, отримує soft TTL = expiriesSecond
+// і hard TTL = expiriesSecond + staleSecond (саме hard TTL передається в драйвер).
+// Після soft TTL OnSet одразу повертає застаріле значення і оновлює його у фоні через fn;
+// лише після hard TTL, коли драйвер уже видалив запис, OnSet блокується на fn.
+func WithStaleWhileRevalidate(staleSecond int) Option {
+	return func(ch *Cache) {
+		ch.staleSecond = staleSecond
+	}
+}
+
+// WithXFetch вмикає імовірнісне дострокове переобчислення (XFetch) для OnSet.
+//
+// Разом зі значенням зберігаються час його обчислення (delta) і момент завершення TTL (expiry).
+// Кожен OnSet-хіт переобчислює значення синхронно, якщо
+//
+//	now - delta * beta * ln(rand()) >= expiry
+//
+// Тобто що ближче expiry і що дорожче обчислення, то вища ймовірність, що один з викликачів
+// оновить значення до його зникнення. Оскільки метадані зберігаються в драйвері,
+// захист працює і між процесами, які ділять одне сховище.
+// beta = 1 — рекомендоване значення; beta > 1 переобчислює раніше, beta < 1 — пізніше.
+func WithXFetch(beta float64) Option {
+	return func(ch *Cache) {
+		ch.xfetchBeta = beta
+	}
+}
+
+// WithNegativeTTL задає TTL "надгробків" — записів про те, що значення не існує
+// (див. ErrNotFoundCacheable). За замовчуванням надгробок живе стільки ж, скільки значення (expiriesSecond OnSet).
+func WithNegativeTTL(seconds int) Option {
+	return func(ch *Cache) {
+		ch.negativeSecond = seconds
+	}
+}
+
+// WithClock підміняє джерело поточного часу (зручно в тестах). Від нього залежать:
+//   - soft TTL (WithStaleWhileRevalidate), XFetch і час обчислення в OnSet;
+//   - момент завершення TTL лічильника в запасному Incr (див. Incr);
+//   - вік бази WithDeltaLog, за яким обмежується TTL versionKey і журналу;
+//   - вік сторінок WithPages, за яким коміт переписує сторінку, поки вона не зникла за TTL;
+//   - початкові версії нових чанків і лічильників тегів (див. SetDependent, InvalidateTag).
+//
+// Драйвер відлічує TTL за реальним часом. Годинник, що відстає від нього чи стоїть, дає журналу
+// довший TTL, ніж лишилося базі, пропускає переписування сторінок, а початкові версії можуть
+// повторитися. Поза тестами годинник має йти разом із реальним часом.
+func WithClock(now func() time.Time) Option {
+	return func(ch *Cache) {
+		ch.now = now
+	}
+}
+
+// WithRand підміняє джерело випадкових чисел для XFetch.
+// rnd має повертати значення з інтервалу (0, 1].
+func WithRand(rnd func() float64) Option {
+	return func(ch *Cache) {
+		ch.rand = rnd
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotIterable повертається Scan і DelPrefix, якщо драйвер не реалізує IterableDriver.
+var ErrNotIterable = errors.New("драйвер не підтримує обхід ключів")
+
+// Scan викликає fn для кожного ключа з префіксом prefix, доки fn повертає true.
+// Службові ключі Cache (чанки тощо) не потрапляють в обхід.
+//
+// Порядок обходу залежить від драйвера (Badger — відсортовано, freecache — довільний).
+// fn не повинна змінювати кеш; для видалення за префіксом є DelPrefix.
+// Повертає ErrNotIterable, якщо драйвер не реалізує IterableDriver.
+func (ch *Cache) Scan(prefix []byte, fn func(key, val []byte) bool) error {
+	return ch.ScanCtx(context.Background(), prefix, fn)
+}
+
+func (ch *Cache) ScanCtx(ctx context.Context, prefix []byte, fn func(key, val []byte) bool) error {
+	return ch.scan(ctx, userKey(prefix), func(key, val []byte) bool {
+		if isInternalKey(key) {
+			return true
+		}
+		return fn(fromUserKey(key), val)
+	})
+}
+
+// scan обходить ключі сховища з префіксом prefix і розгортає конверти значень.
+// fn отримує ключі без префікса простору імен (див. Namespace).
+func (ch *Cache) scan(ctx context.Context, prefix []byte, fn func(key, val []byte) bool) error {
+	id, ok := ch.dr.(IterableDriver)
+	if !ok {
+		return ErrNotIterable
+	}
+	var decodeErr error
+	err := id.Scan(ctx, ch.storageKey(prefix), func(key, raw []byte) bool {
+		env, err := decodeEntry(raw)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		if env.Tombstone {
+			return true
+		}
+		return fn(key[len(ch.prefix):], env.Value)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// DelPrefix видаляє всі ключі з префіксом prefix (порожній — усі ключі користувача).
+// Службові ключі Cache не видаляються.
+//
+// Якщо драйвер реалізує PrefixDeleter (Badger — DropPrefix), видалення виконує драйвер;
+// інакше ключі збираються через Scan і видаляються DelMany.
+// Повертає ErrNotIterable, якщо драйвер не підтримує жодного з варіантів.
+func (ch *Cache) DelPrefix(prefix []byte) error {
+	return ch.DelPrefixCtx(context.Background(), prefix)
+}
+
+func (ch *Cache) DelPrefixCtx(ctx context.Context, prefix []byte) error {
+	// непорожній префікс після userKey не може збігтися зі службовими ключами,
+	// а порожній покриває і їх, тож його обробляє лише обхід з фільтром
+	if len(prefix) == 0 {
+		return ch.delScanned(ctx, nil, isInternalKey)
+	}
+	return ch.delPrefix(ctx, userKey(prefix))
+}
+
+// delPrefix видаляє всі ключі сховища з префіксом prefix.
+func (ch *Cache) delPrefix(ctx context.Context, prefix []byte) error {
+	if pd, ok := ch.dr.(PrefixDeleter); ok {
+		return pd.DelPrefix(ctx, ch.storageKey(prefix))
+	}
+	return ch.delScanned(ctx, prefix, nil)
+}
+
+// delScanned збирає ключі з префіксом через обхід (крім тих, для яких skip повертає true) і видаляє їх.
+func (ch *Cache) delScanned(ctx context.Context, prefix []byte, skip func(key []byte) bool) error {
+	id, ok := ch.dr.(IterableDriver)
+	if !ok {
+		return ErrNotIterable
+	}
+	var keys [][]byte
+	err := id.Scan(ctx, ch.storageKey(prefix), func(key, _ []byte) bool {
+		if key = key[len(ch.prefix):]; skip == nil || !skip(key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return ch.delMany(ctx, keys)
+}
@@ -0,0 +1,88 @@
+package cache_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestScanFreeCache(t *testing.T) {
+	testScan(t, newFreeCache())
+}
+
+func TestScanBadgerDB(t *testing.T) {
+	testScan(t, newBadgerCache(t))
+}
+
+func testScan(t *testing.T, c *cache.Cache) {
+	for _, key := range []string{"tenant:42:a", "tenant:42:b", "tenant:43:a", "\x00tenant:42:c"} {
+		if err := c.Set([]byte(key), []byte("v-"+key), 60); err != nil {
+			t.Fatalf("Set(%q): %v", key, err)
+		}
+	}
+	chunk, err := c.Chunk("scan_chunk", 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	chunk.SetRaw([]byte("k"), []byte("v"))
+	if err := chunk.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges(): %v", err)
+	}
+
+	scan := func(prefix string) []string {
+		t.Helper()
+		var keys []string
+		err := c.Scan([]byte(prefix), func(key, val []byte) bool {
+			if string(val) != "v-"+string(key) {
+				t.Fatalf("Scan(): %q has value %q", key, val)
+			}
+			keys = append(keys, string(key))
+			return true
+		})
+		if err != nil {
+			t.Fatalf("Scan(%q): %v", prefix, err)
+		}
+		slices.Sort(keys)
+		return keys
+	}
+
+	if got := scan("tenant:42:"); !slices.Equal(got, []string{"tenant:42:a", "tenant:42:b"}) {
+		t.Fatalf("Scan(tenant:42:): got %q", got)
+	}
+	// службові ключі чанку не видно
+	if got := scan(""); len(got) != 4 {
+		t.Fatalf("Scan(\"\"): expected 4 user keys, got %q", got)
+	}
+
+	visited := 0
+	if err := c.Scan(nil, func(key, val []byte) bool { visited++; return false }); err != nil || visited != 1 {
+		t.Fatalf("Scan() stop: visited=%d err=%v", visited, err)
+	}
+
+	if err := c.DelPrefix([]byte("tenant:42:")); err != nil {
+		t.Fatalf("DelPrefix(): %v", err)
+	}
+	if got := scan("tenant:"); !slices.Equal(got, []string{"tenant:43:a"}) {
+		t.Fatalf("after DelPrefix(tenant:42:): got %q", got)
+	}
+
+	if err := c.DelPrefix(nil); err != nil {
+		t.Fatalf("DelPrefix(nil): %v", err)
+	}
+	if got := scan(""); len(got) != 0 {
+		t.Fatalf("after DelPrefix(nil): got %q", got)
+	}
+	expectRaw(t, c, "scan_chunk", "k", "v")
+}
+
+func TestScanNotIterable(t *testing.T) {
+	c := cache.NewCache(plainDriver{newFreeCacheDriver()})
+	if err := c.Scan(nil, func(key, val []byte) bool { return true }); !errors.Is(err, cache.ErrNotIterable) {
+		t.Fatalf("Scan(): expected ErrNotIterable, got %v", err)
+	}
+	if err := c.DelPrefix([]byte("x")); !errors.Is(err, cache.ErrNotIterable) {
+		t.Fatalf("DelPrefix(): expected ErrNotIterable, got %v", err)
+	}
+}
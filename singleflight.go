@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errFlightPanic отримують очікувачі, якщо fn лідера запанікував.
+var errFlightPanic = errors.New("cache: single-flight loader panicked")
+
+// flightCall — один виконуваний виклик завантажувача, на результат якого чекають дублікати.
+type flightCall struct {
+	done chan struct{}
+	val  []byte
+	err  error
+}
+
+// flightGroup обʼєднує конкурентні виклики з однаковим ключем в один виклик fn.
+// Це мінімальна реалізація singleflight без залежності від golang.org/x/sync.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do виконує fn для key, якщо для нього ще немає активного виклику;
+// інакше чекає на результат активного виклику.
+//
+// Повертає shared=true, якщо результат отримано від іншого (лідерського) виклику.
+// Очікувач, чий ctx скасовано, повертається з ctx.Err(), не перериваючи лідера.
+// Помилку лідера (включно з помилкою його ctx) отримують усі очікувачі.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() ([]byte, error)) (val []byte, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err(), true
+		}
+		if c.err != nil {
+			return nil, c.err, true
+		}
+		// кожен очікувач отримує власну копію, щоб не ділити мутабельний слайс
+		valCopy := make([]byte, len(c.val))
+		copy(valCopy, c.val)
+		return valCopy, nil, true
+	}
+
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.err = errFlightPanic
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
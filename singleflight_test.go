@@ -0,0 +1,119 @@
+package cache_test
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestOnSetSingleFlight(t *testing.T) {
+	const n = 50
+	c := newFreeCache()
+
+	var calls atomic.Int32
+	errs := make(chan error, n)
+	runConcurrently(n, func(int) {
+		val, err := c.OnSet([]byte("sf key"), func() ([]byte, error) {
+			calls.Add(1)
+			time.Sleep(100 * time.Millisecond)
+			return Value, nil
+		}, 60)
+		if err == nil && !bytes.Equal(val, Value) {
+			err = errors.New("unexpected value " + string(val))
+		}
+		errs <- err
+	})
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("OnSet(): %v", err)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected loader calls=1, got %d", calls.Load())
+	}
+	if got := c.Stats().OnSetCoalesced; got != n-1 {
+		t.Fatalf("expected OnSetCoalesced=%d, got %d", n-1, got)
+	}
+}
+
+func TestOnSetSingleFlightSharesError(t *testing.T) {
+	const n = 10
+	c := newFreeCache()
+	errLoad := errors.New("load failed")
+
+	var (
+		calls  atomic.Int32
+		failed atomic.Int32
+	)
+	runConcurrently(n, func(int) {
+		_, err := c.OnSet([]byte("sf err key"), func() ([]byte, error) {
+			calls.Add(1)
+			time.Sleep(100 * time.Millisecond)
+			return nil, errLoad
+		}, 60)
+		if errors.Is(err, errLoad) {
+			failed.Add(1)
+		}
+	})
+
+	if calls.Load() != 1 || failed.Load() != n {
+		t.Fatalf("expected calls=1 failed=%d, got calls=%d failed=%d", n, calls.Load(), failed.Load())
+	}
+}
+
+func TestOnSetWithoutSingleFlight(t *testing.T) {
+	const n = 10
+	c := newFreeCache(cache.WithoutSingleFlight())
+
+	var calls atomic.Int32
+	runConcurrently(n, func(int) {
+		c.OnSet([]byte("no sf key"), func() ([]byte, error) {
+			calls.Add(1)
+			time.Sleep(100 * time.Millisecond)
+			return Value, nil
+		}, 60)
+	})
+
+	if calls.Load() < 2 {
+		t.Fatalf("expected independent loader calls, got %d", calls.Load())
+	}
+	if got := c.Stats().OnSetCoalesced; got != 0 {
+		t.Fatalf("expected OnSetCoalesced=0, got %d", got)
+	}
+}
+
+func TestChunkOnSetSingleFlight(t *testing.T) {
+	const n = 20
+	c := newFreeCache()
+
+	ch, err := c.Chunk("chunk_sf", 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+
+	var calls atomic.Int32
+	runConcurrently(n, func(int) {
+		var got testObj
+		err := ch.OnSet([]byte("k"), &got, func() (any, error) {
+			calls.Add(1)
+			time.Sleep(100 * time.Millisecond)
+			return testObj{A: 1, B: "sf"}, nil
+		})
+		if err != nil || got.A != 1 {
+			t.Errorf("OnSet(): got=%+v err=%v", got, err)
+		}
+	})
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected loader calls=1, got %d", calls.Load())
+	}
+	if got := c.Stats().OnSetCoalesced; got != n-1 {
+		t.Fatalf("expected OnSetCoalesced=%d, got %d", n-1, got)
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// storeLoaded записує значення, отримане від завантажувача OnSet за час delta.
+// У режимі stale-while-revalidate значення загортається в конверт із soft TTL,
+// а в драйвер передається hard TTL; у режимі XFetch у конверт пишуться expiry та delta.
+func (ch *Cache) storeLoaded(ctx context.Context, key, val []byte, expiriesSecond int, delta time.Duration) error {
+	env := envelope{Value: val}
+	ttl := expiriesSecond
+	if expiriesSecond > 0 {
+		expire := ch.now().Add(time.Duration(expiriesSecond) * time.Second).UnixNano()
+		if ch.staleSecond > 0 {
+			env.SoftExpire = expire
+			ttl = expiriesSecond + ch.staleSecond
+		}
+		if ch.xfetchBeta > 0 {
+			env.Expire = expire
+			env.Delta = max(int64(delta), 1)
+		}
+	}
+	return ch.setEntry(ctx, key, env, ttl)
+}
+
+// storeTombstone записує надгробок ключа з TTL WithNegativeTTL (або expiriesSecond, якщо його не задано).
+func (ch *Cache) storeTombstone(ctx context.Context, key []byte, expiriesSecond int) error {
+	ttl := expiriesSecond
+	if ch.negativeSecond > 0 {
+		ttl = ch.negativeSecond
+	}
+	return ch.setEntry(ctx, key, envelope{Tombstone: true}, ttl)
+}
+
+// isStale повідомляє, чи минув soft TTL запису.
+func (ch *Cache) isStale(env *envelope) bool {
+	return env.SoftExpire != 0 && ch.now().UnixNano() >= env.SoftExpire
+}
+
+// revalidate запускає фонове оновлення ключа через fn.
+// Для одного ключа одночасно виконується не більше одного фонового оновлення.
+// Фонове оновлення успадковує значення ctx, але не його скасування.
+// fn виконується вже після повернення OnSet, тож не повинен писати у стан викликача без синхронізації.
+func (ch *Cache) revalidate(ctx context.Context, key []byte, fn OnSetCtx, expiriesSecond int) {
+	ch.stats.staleServed.Add(1)
+
+	k := string(key)
+	if _, busy := ch.refreshing.LoadOrStore(k, struct{}{}); busy {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	key = bytes.Clone(key)
+
+	ch.bg.Add(1)
+	go func() {
+		defer ch.bg.Done()
+		defer ch.refreshing.Delete(k)
+
+		if _, err := ch.load(ctx, key, fn, expiriesSecond); err != nil {
+			ch.stats.refreshErrors.Add(1)
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package cache_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestOnSetStaleWhileRevalidate(t *testing.T) {
+	c := newFreeCache(cache.WithStaleWhileRevalidate(2))
+	key := []byte("swr key")
+
+	v1, v2 := []byte("v1"), []byte("v2")
+	if _, err := c.OnSet(key, func() ([]byte, error) { return v1, nil }, 1); err != nil {
+		t.Fatalf("OnSet(): %v", err)
+	}
+
+	// soft TTL минув, hard TTL — ні: отримуємо v1 одразу, v2 вантажиться у фоні
+	time.Sleep(1100 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	start := time.Now()
+	val, err := c.OnSet(key, func() ([]byte, error) {
+		defer close(refreshed)
+		time.Sleep(200 * time.Millisecond)
+		return v2, nil
+	}, 1)
+	if err != nil {
+		t.Fatalf("OnSet() stale: %v", err)
+	}
+	if !bytes.Equal(val, v1) {
+		t.Fatalf("OnSet() stale: want=%q got=%q", v1, val)
+	}
+	if time.Since(start) >= 200*time.Millisecond {
+		t.Fatalf("OnSet() stale: caller was blocked on the loader")
+	}
+	if got := c.Stats().StaleServed; got != 1 {
+		t.Fatalf("expected StaleServed=1, got %d", got)
+	}
+
+	<-refreshed
+	deadline := time.Now().Add(time.Second)
+	for {
+		val, _, err = c.Get(key)
+		if err != nil {
+			t.Fatalf("Get(): %v", err)
+		}
+		if bytes.Equal(val, v2) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Get(): background refresh not stored, got=%q", val)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// після hard TTL запису немає — OnSet блокується на завантажувачі
+	time.Sleep(3500 * time.Millisecond)
+	calls := 0
+	val, err = c.OnSet(key, func() ([]byte, error) {
+		calls++
+		return v1, nil
+	}, 1)
+	if err != nil {
+		t.Fatalf("OnSet() after hard ttl: %v", err)
+	}
+	if calls != 1 || !bytes.Equal(val, v1) {
+		t.Fatalf("OnSet() after hard ttl: calls=%d got=%q", calls, val)
+	}
+}
+
+func TestEnvelopeEscaping(t *testing.T) {
+	c := newFreeCache()
+
+	// сирі байти, що починаються як службовий конверт, мають повертатися без змін
+	raw := []byte{0x00, 0xCE, 'e', 'n', 'v', 0x01, 'x'}
+	if err := c.Set(Key, raw, 60); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	val, exist, err := c.Get(Key)
+	if err != nil || !exist || !bytes.Equal(val, raw) {
+		t.Fatalf("Get(): want=%v got=%v exist=%v err=%v", raw, val, exist, err)
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import "sync/atomic"
+
+// Stats — знімок лічильників Cache.
+type Stats struct {
+	// OnSetCoalesced — скільки викликів OnSet (Cache і Chunk) не викликали завантажувач,
+	// а отримали результат конкурентного виклику для того ж ключа.
+	OnSetCoalesced uint64
+
+	// StaleServed — скільки разів OnSet повернув застаріле значення (stale-while-revalidate).
+	StaleServed uint64
+	// RefreshErrors — скільки фонових оновлень завершилися помилкою.
+	RefreshErrors uint64
+	// EarlyRecomputes — скільки разів XFetch достроково переобчислив значення в OnSet.
+	EarlyRecomputes uint64
+}
+
+// cacheStats — атомарні лічильники, з яких формується Stats.
+type cacheStats struct {
+	onSetCoalesced  atomic.Uint64
+	staleServed     atomic.Uint64
+	refreshErrors   atomic.Uint64
+	earlyRecomputes atomic.Uint64
+}
+
+// Stats повертає поточні значення лічильників.
+func (ch *Cache) Stats() Stats {
+	return Stats{
+		OnSetCoalesced:  ch.stats.onSetCoalesced.Load(),
+		StaleServed:     ch.stats.staleServed.Load(),
+		RefreshErrors:   ch.stats.refreshErrors.Load(),
+		EarlyRecomputes: ch.stats.earlyRecomputes.Load(),
+	}
+}
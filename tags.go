@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+)
+
+// getTagKey — ключ лічильника версії тегу.
+func getTagKey(tag string) []byte {
+	return internalKey(keyKindTag, tag, 'v')
+}
+
+// SetWithTags записує значення, повʼязане з тегами tags (див. InvalidateTag).
+//
+// Разом зі значенням зберігаються поточні версії тегів; Get, GetMany, OnSet та інші читання
+// вважають запис відсутнім, щойно версія хоча б одного з тегів змінилась або її лічильник зник
+// з драйвера. Сам запис залишається в драйвері до завершення TTL. Scan версії тегів не перевіряє.
+//
+// Версії читаються під час запису, тож значення, обчислене до InvalidateTag і записане після нього,
+// вважатиметься актуальним: обчислюйте значення вже після читання даних, від яких воно залежить.
+func (ch *Cache) SetWithTags(key, val []byte, expiriesSecond int, tags ...string) error {
+	return ch.SetWithTagsCtx(context.Background(), key, val, expiriesSecond, tags...)
+}
+
+func (ch *Cache) SetWithTagsCtx(ctx context.Context, key, val []byte, expiriesSecond int, tags ...string) error {
+	versions, err := ch.tagVersions(ctx, tags)
+	if err != nil {
+		return err
+	}
+	return ch.setEntry(ctx, userKey(key), envelope{Value: val, Tags: versions}, expiriesSecond)
+}
+
+// InvalidateTag робить недійсними всі записи з тегом tag за O(1): збільшує лічильник версії тегу.
+// Лічильники тегів зберігаються в драйвері без TTL, тож invalidation бачать усі процеси,
+// які ділять сховище. Відсутній (витіснений) лічильник створюється так само, як у SetWithTags.
+func (ch *Cache) InvalidateTag(tag string) error {
+	return ch.InvalidateTagCtx(context.Background(), tag)
+}
+
+func (ch *Cache) InvalidateTagCtx(ctx context.Context, tag string) error {
+	key := getTagKey(tag)
+	for {
+		val, exist, err := ch.get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			// без лічильника записи з тегом уже недійсні; новий лічильник не може почати з версії,
+			// яку записав хтось зі старих записів (див. tagVersions)
+			set, err := ch.setNX(ctx, key, formatCounter(ch.now().UnixNano()), 0)
+			if err != nil || set {
+				return err
+			}
+			continue
+		}
+		ver, err := parseTagVersion(val)
+		if err != nil {
+			return err
+		}
+		swapped, err := ch.cas(ctx, key, val, strconv.AppendUint(nil, ver+1, 10), 0)
+		if err != nil || swapped {
+			return err
+		}
+	}
+}
+
+// tagVersions повертає поточні версії тегів, створюючи лічильники відсутніх.
+//
+// Новий лічильник починається з поточного часу в наносекундах, а не з нуля: якщо лічильник
+// витіснено з драйвера, записи зі старою версією не "оживуть" після його повторного створення.
+func (ch *Cache) tagVersions(ctx context.Context, tags []string) (map[string]uint64, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	versions := make(map[string]uint64, len(tags))
+	for {
+		keys := make([][]byte, 0, len(tags))
+		for _, tag := range tags {
+			if _, ok := versions[tag]; !ok {
+				keys = append(keys, getTagKey(tag))
+			}
+		}
+		if len(keys) == 0 {
+			return versions, nil
+		}
+		vals, err := ch.getMany(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			val, ok := vals[string(getTagKey(tag))]
+			if !ok {
+				continue
+			}
+			if versions[tag], err = parseTagVersion(val); err != nil {
+				return nil, err
+			}
+		}
+		// після невдалого SetNX лічильник уже створив інший writer — повторне читання його побачить
+		for _, tag := range tags {
+			if _, ok := versions[tag]; ok {
+				continue
+			}
+			initial := uint64(ch.now().UnixNano())
+			set, err := ch.setNX(ctx, getTagKey(tag), formatCounter(int64(initial)), 0)
+			if err != nil {
+				return nil, err
+			}
+			if set {
+				versions[tag] = initial
+			}
+		}
+	}
+}
+
+func parseTagVersion(val []byte) (uint64, error) {
+	ver, err := strconv.ParseUint(string(val), 10, 64)
+	if err != nil {
+		return 0, ErrNotCounter
+	}
+	return ver, nil
+}
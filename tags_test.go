@@ -0,0 +1,113 @@
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestTagsFreeCache(t *testing.T) {
+	testTags(t, newFreeCache())
+}
+
+func TestTagsBadgerDB(t *testing.T) {
+	testTags(t, newBadgerCache(t))
+}
+
+func testTags(t *testing.T, c *cache.Cache) {
+	set := func(key string, tags ...string) {
+		t.Helper()
+		if err := c.SetWithTags([]byte(key), []byte("v-"+key), 60, tags...); err != nil {
+			t.Fatalf("SetWithTags(%q): %v", key, err)
+		}
+	}
+	expect := func(key string, want bool) {
+		t.Helper()
+		val, exist, err := c.Get([]byte(key))
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		if exist != want || exist && string(val) != "v-"+key {
+			t.Fatalf("Get(%q) = %q, %v; want exist=%v", key, val, exist, want)
+		}
+	}
+
+	set("fragment:user", "user:1")
+	set("fragment:both", "user:1", "post:7")
+	set("fragment:post", "post:7")
+	expect("fragment:user", true)
+	expect("fragment:both", true)
+	expect("fragment:post", true)
+
+	if err := c.InvalidateTag("user:1"); err != nil {
+		t.Fatalf("InvalidateTag(): %v", err)
+	}
+	expect("fragment:user", false)
+	expect("fragment:both", false)
+	expect("fragment:post", true)
+
+	vals, err := c.GetMany([][]byte{[]byte("fragment:user"), []byte("fragment:both"), []byte("fragment:post")})
+	if err != nil {
+		t.Fatalf("GetMany(): %v", err)
+	}
+	if len(vals) != 1 || string(vals["fragment:post"]) != "v-fragment:post" {
+		t.Fatalf("GetMany(): expected only fragment:post, got %q", vals)
+	}
+
+	// повторний запис бере нову версію тегу
+	set("fragment:user", "user:1")
+	expect("fragment:user", true)
+
+	// OnSet переобчислює запис із застарілим тегом
+	if err := c.InvalidateTag("user:1"); err != nil {
+		t.Fatalf("InvalidateTag(): %v", err)
+	}
+	calls := 0
+	val, err := c.OnSet([]byte("fragment:user"), func() ([]byte, error) {
+		calls++
+		return []byte("v-fragment:user"), nil
+	}, 60)
+	if err != nil || calls != 1 || string(val) != "v-fragment:user" {
+		t.Fatalf("OnSet() = %q, %v; loader calls %d", val, err, calls)
+	}
+
+	// тег без записів інвалідовується без помилок, а теги просторів імен незалежні
+	if err := c.InvalidateTag("unknown"); err != nil {
+		t.Fatalf("InvalidateTag(unknown): %v", err)
+	}
+	ns := c.Namespace("tags")
+	if err := ns.SetWithTags([]byte("k"), []byte("v"), 60, "post:7"); err != nil {
+		t.Fatalf("SetWithTags(): %v", err)
+	}
+	if err := c.InvalidateTag("post:7"); err != nil {
+		t.Fatalf("InvalidateTag(): %v", err)
+	}
+	expect("fragment:post", false)
+	if _, exist, err := ns.Get([]byte("k")); err != nil || !exist {
+		t.Fatalf("namespace Get() = %v, %v; want entry to survive root invalidation", exist, err)
+	}
+}
+
+// Лічильник тегу, витіснений з драйвера, не повертається до версії, яку записали старі записи.
+func TestTagsEvictedCounter(t *testing.T) {
+	dr := newFreeCacheDriver()
+	c := cache.NewCache(dr)
+	tagKey := []byte{0x00, 0x01, 't', byte(len("users")), 'u', 's', 'e', 'r', 's', 'v'}
+
+	for i := 0; i < 3; i++ {
+		key := []byte(fmt.Sprintf("k%d", i))
+		if err := c.SetWithTags(key, []byte("v"), 60, "users"); err != nil {
+			t.Fatalf("SetWithTags(): %v", err)
+		}
+		if err := dr.Del(tagKey); err != nil {
+			t.Fatalf("Del(tag counter): %v", err)
+		}
+		if err := c.InvalidateTag("users"); err != nil {
+			t.Fatalf("InvalidateTag(): %v", err)
+		}
+		if _, exist, err := c.Get(key); err != nil || exist {
+			t.Fatalf("Get(%s) after InvalidateTag: exist=%v err=%v", key, exist, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package cache_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/drivers"
+)
+
+// newTiered повертає TieredDriver freecache → Badger і обидва його рівні.
+func newTiered(t *testing.T, opts ...drivers.TieredOption) (*drivers.TieredDriver, cache.CacheDriver, *drivers.BadgerDBDriver) {
+	t.Helper()
+	l2, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	l1 := drivers.NewFreeCacheDriver(freecache.NewCache(100 * 1024 * 1024))
+	dr := drivers.NewTieredDriver(l1, l2, opts...)
+	t.Cleanup(func() { dr.Close() })
+	return dr, l1, l2
+}
+
+func TestTieredDriver(t *testing.T) {
+	for name, opts := range map[string][]drivers.TieredOption{
+		"write-through": nil,
+		"write-around":  {drivers.WithWriteAround()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dr, _, _ := newTiered(t, opts...)
+			c := cache.NewCache(dr)
+			testLogic(t, c)
+			testLogicChunk(t, c)
+		})
+	}
+}
+
+func TestTieredDriverTiers(t *testing.T) {
+	dr, l1, l2 := newTiered(t)
+	has := func(tier cache.CacheDriver, key string) bool {
+		t.Helper()
+		_, exist, err := tier.Get([]byte(key))
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		return exist
+	}
+
+	// write-through пише в обидва рівні
+	if err := dr.Set([]byte("through"), []byte("v"), 60); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if !has(l1, "through") || !has(l2, "through") {
+		t.Fatal("write-through: expected key in both tiers")
+	}
+
+	// промах L1 піднімається з L2 із залишковим TTL L2
+	if err := l2.Set([]byte("promoted"), []byte("v"), 2); err != nil {
+		t.Fatalf("l2.Set(): %v", err)
+	}
+	if val, exist, err := dr.Get([]byte("promoted")); err != nil || !exist || string(val) != "v" {
+		t.Fatalf("Get() = %q, %v, %v", val, exist, err)
+	}
+	if !has(l1, "promoted") {
+		t.Fatal("expected key to be promoted into L1")
+	}
+	time.Sleep(3 * time.Second)
+	if has(l1, "promoted") {
+		t.Fatal("L1 entry outlived L2 TTL")
+	}
+
+	// Del і Clear діють на обидва рівні
+	if err := dr.Del([]byte("through")); err != nil {
+		t.Fatalf("Del(): %v", err)
+	}
+	if has(l1, "through") || has(l2, "through") {
+		t.Fatal("Del: expected key to be removed from both tiers")
+	}
+	for _, key := range []string{"a", "b"} {
+		if err := dr.Set([]byte(key), []byte("v"), 0); err != nil {
+			t.Fatalf("Set(): %v", err)
+		}
+	}
+	if err := dr.Clear(); err != nil {
+		t.Fatalf("Clear(): %v", err)
+	}
+	if has(l1, "a") || has(l2, "b") {
+		t.Fatal("Clear: expected both tiers to be empty")
+	}
+
+	// умовні записи виконуються в L2 і скидають L1
+	if err := dr.Set([]byte("n"), []byte("1"), 0); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if n, err := dr.Incr(context.Background(), []byte("n"), 2, 0); err != nil || n != 3 {
+		t.Fatalf("Incr() = %d, %v", n, err)
+	}
+	if val, _, _ := dr.Get([]byte("n")); string(val) != "3" {
+		t.Fatalf("Get() after Incr = %q, want 3", val)
+	}
+}
+
+func TestTieredDriverWriteAround(t *testing.T) {
+	dr, l1, l2 := newTiered(t, drivers.WithWriteAround(), drivers.WithL1MaxTTL(1))
+	if err := dr.Set([]byte("k"), []byte("v"), 0); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if _, exist, _ := l1.Get([]byte("k")); exist {
+		t.Fatal("write-around: Set must not populate L1")
+	}
+	if _, exist, _ := dr.Get([]byte("k")); !exist {
+		t.Fatal("Get(): expected hit from L2")
+	}
+	if _, exist, _ := l1.Get([]byte("k")); !exist {
+		t.Fatal("Get(): expected key to be promoted into L1")
+	}
+
+	// запис в L2 повз TieredDriver стає видимим після WithL1MaxTTL
+	if err := l2.Set([]byte("k"), []byte("v2"), 0); err != nil {
+		t.Fatalf("l2.Set(): %v", err)
+	}
+	time.Sleep(2 * time.Second)
+	if val, _, _ := dr.Get([]byte("k")); string(val) != "v2" {
+		t.Fatalf("Get() = %q, want v2 after L1 max TTL", val)
+	}
+}
+
+// countingTTLDriver рахує виклики GetWithTTL, щоб перевірити пакетне читання промахів L1.
+type countingTTLDriver struct {
+	*drivers.BadgerDBDriver
+	calls atomic.Int32
+}
+
+func (d *countingTTLDriver) GetWithTTL(ctx context.Context, key []byte) ([]byte, int, bool, error) {
+	d.calls.Add(1)
+	return d.BadgerDBDriver.GetWithTTL(ctx, key)
+}
+
+func TestTieredDriverBatch(t *testing.T) {
+	ctx := context.Background()
+	for name, tc := range map[string]struct {
+		l2      func(*drivers.BadgerDBDriver) cache.CacheDriver
+		promote bool
+	}{
+		"batch": {func(b *drivers.BadgerDBDriver) cache.CacheDriver { return &countingTTLDriver{BadgerDBDriver: b} }, true},
+		"ttl":   {func(b *drivers.BadgerDBDriver) cache.CacheDriver { return ttlOnlyDriver{b, b} }, true},
+		"plain": {func(b *drivers.BadgerDBDriver) cache.CacheDriver { return plainDriver{b} }, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			badger, err := drivers.NewBadgerDBDriver(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewBadgerDBDriver(): %v", err)
+			}
+			l1, l2 := newFreeCacheDriver(), tc.l2(badger)
+			dr := drivers.NewTieredDriver(l1, l2)
+			t.Cleanup(func() { dr.Close() })
+			has := func(tier cache.CacheDriver, key string) bool {
+				t.Helper()
+				_, exist, err := tier.Get([]byte(key))
+				if err != nil {
+					t.Fatalf("Get(%q): %v", key, err)
+				}
+				return exist
+			}
+
+			// SetMany у режимі write-through пише в обидва рівні
+			if err := dr.SetMany(ctx, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, 60); err != nil {
+				t.Fatalf("SetMany(): %v", err)
+			}
+			if !has(l1, "a") || !has(l1, "b") || !has(badger, "a") || !has(badger, "b") {
+				t.Fatal("SetMany: expected keys in both tiers")
+			}
+
+			// промахи L1 читаються з L2 і піднімаються в L1 із залишковим TTL L2
+			if err := badger.Set([]byte("c"), []byte("3"), 30); err != nil {
+				t.Fatalf("l2.Set(): %v", err)
+			}
+			vals, err := dr.GetMany(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("missing")})
+			if err != nil {
+				t.Fatalf("GetMany(): %v", err)
+			}
+			if len(vals) != 3 || string(vals["a"]) != "1" || string(vals["b"]) != "2" || string(vals["c"]) != "3" {
+				t.Fatalf("GetMany() = %q", vals)
+			}
+			_, ttl, exist, err := l1.(drivers.TTLDriver).GetWithTTL(ctx, []byte("c"))
+			if err != nil {
+				t.Fatalf("l1.GetWithTTL(): %v", err)
+			}
+			if exist != tc.promote {
+				t.Fatalf("GetMany: promoted=%v, want %v", exist, tc.promote)
+			}
+			if exist && (ttl <= 0 || ttl > 30) {
+				t.Fatalf("GetMany: L1 TTL = %d, want within L2 TTL 30", ttl)
+			}
+			if cd, ok := l2.(*countingTTLDriver); ok && cd.calls.Load() != 0 {
+				t.Fatalf("GetMany: %d GetWithTTL calls, want one batched read", cd.calls.Load())
+			}
+
+			// DelMany видаляє ключі з обох рівнів
+			if err := dr.DelMany(ctx, [][]byte{[]byte("a"), []byte("c")}); err != nil {
+				t.Fatalf("DelMany(): %v", err)
+			}
+			for _, key := range []string{"a", "c"} {
+				if has(l1, key) || has(badger, key) {
+					t.Fatalf("DelMany: expected %q to be removed from both tiers", key)
+				}
+			}
+			if !has(l1, "b") {
+				t.Fatal("DelMany: removed a key outside the batch")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+)
+
+// commitIf записує items, лише якщо значення condKey дорівнює old (old == nil — ключ відсутній).
+// items обовʼязково містить нове значення condKey. Значення передаються без конверта.
+//
+// Гарантії залежать від драйвера:
+//   - TxDriver: перевірка і всі записи атомарні відносно інших CommitIf (Badger — одна транзакція;
+//     FreeCache — послідовні записи під lock-ом у межах процесу, без стійкості до збою між ними);
+//   - AtomicDriver: спершу condKey змінюється через CAS/SetNX, тож з паралельних writer-ів
+//     перемагає рівно один, а потім пишуться решта items. Падіння між цими кроками залишає
+//     condKey новішим за решту ключів;
+//   - інші драйвери: перевірка і записи серіалізуються смугастим lock-ом лише в межах процесу,
+//     решта items пишуться перед condKey.
+func (ch *Cache) commitIf(ctx context.Context, condKey, old []byte, items map[string][]byte, expiriesSecond int) (bool, error) {
+	condKey, items = ch.storageKey(condKey), ch.storageItems(items)
+	raws := make(map[string][]byte, len(items))
+	for key, val := range items {
+		raw, err := encodeEntry(envelope{Value: val})
+		if err != nil {
+			return false, err
+		}
+		raws[key] = raw
+	}
+	var oldRaw []byte
+	if old != nil {
+		var err error
+		if oldRaw, err = encodeEntry(envelope{Value: old}); err != nil {
+			return false, err
+		}
+	}
+	condRaw := raws[string(condKey)]
+	delete(raws, string(condKey))
+
+	if td, ok := ch.dr.(TxDriver); ok {
+		raws[string(condKey)] = condRaw
+		return td.CommitIf(ctx, condKey, oldRaw, raws, expiriesSecond)
+	}
+
+	if ad, ok := ch.dr.(AtomicDriver); ok {
+		var (
+			swapped bool
+			err     error
+		)
+		if oldRaw == nil {
+			swapped, err = ad.SetIfNotExists(ctx, condKey, condRaw, expiriesSecond)
+		} else {
+			swapped, err = ad.CompareAndSwap(ctx, condKey, oldRaw, condRaw, expiriesSecond)
+		}
+		if err != nil || !swapped {
+			return false, err
+		}
+		return true, DriverSetMany(ctx, ch.dr, raws, expiriesSecond)
+	}
+
+	mu := ch.locks.Get(condKey)
+	mu.Lock()
+	defer mu.Unlock()
+
+	cur, exist, err := DriverGet(ctx, ch.dr, condKey)
+	if err != nil {
+		return false, err
+	}
+	if exist != (oldRaw != nil) || !bytes.Equal(cur, oldRaw) {
+		return false, nil
+	}
+	if err := DriverSetMany(ctx, ch.dr, raws, expiriesSecond); err != nil {
+		return false, err
+	}
+	return true, DriverSet(ctx, ch.dr, condKey, condRaw, expiriesSecond)
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// KeyEncoder перетворює типізований ключ у []byte, з яким працює драйвер.
+type KeyEncoder[K any] func(key K) []byte
+
+// ValueCodec кодує типізоване значення у []byte і назад.
+type ValueCodec[V any] interface {
+	Encode(val V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+// StringKey — KeyEncoder для рядкових ключів.
+func StringKey(key string) []byte {
+	return []byte(key)
+}
+
+// MsgpackCodec — ValueCodec за замовчуванням: кодує значення у msgpack,
+// так само як Chunk.Set/Get.
+type MsgpackCodec[V any] struct{}
+
+func (MsgpackCodec[V]) Encode(val V) ([]byte, error) {
+	return msgpack.Marshal(val)
+}
+
+func (MsgpackCodec[V]) Decode(data []byte) (V, error) {
+	var val V
+	err := msgpack.Unmarshal(data, &val)
+	return val, err
+}
+
+// TypedCache — типізований фасад над *Cache.
+// Ключі кодуються через KeyEncoder, значення — через ValueCodec,
+// тож код застосунку не працює з сирими []byte.
+type TypedCache[K, V any] struct {
+	ch     *Cache
+	encKey KeyEncoder[K]
+	codec  ValueCodec[V]
+}
+
+// NewTypedCache створює TypedCache поверх ch.
+// Якщо codec == nil, використовується MsgpackCodec.
+func NewTypedCache[K, V any](ch *Cache, encKey KeyEncoder[K], codec ValueCodec[V]) *TypedCache[K, V] {
+	if codec == nil {
+		codec = MsgpackCodec[V]{}
+	}
+	return &TypedCache[K, V]{ch: ch, encKey: encKey, codec: codec}
+}
+
+// Cache повертає *Cache, поверх якого побудовано фасад.
+func (tc *TypedCache[K, V]) Cache() *Cache {
+	return tc.ch
+}
+
+func (tc *TypedCache[K, V]) Get(key K) (val V, exist bool, err error) {
+	return tc.GetCtx(context.Background(), key)
+}
+
+func (tc *TypedCache[K, V]) GetCtx(ctx context.Context, key K) (val V, exist bool, err error) {
+	raw, exist, err := tc.ch.GetCtx(ctx, tc.encKey(key))
+	if err != nil || !exist {
+		return val, exist, err
+	}
+	val, err = tc.codec.Decode(raw)
+	return val, true, err
+}
+
+func (tc *TypedCache[K, V]) GetAndDel(key K) (val V, exist bool, err error) {
+	return tc.GetAndDelCtx(context.Background(), key)
+}
+
+func (tc *TypedCache[K, V]) GetAndDelCtx(ctx context.Context, key K) (val V, exist bool, err error) {
+	raw, exist, err := tc.ch.GetAndDelCtx(ctx, tc.encKey(key))
+	if err != nil || !exist {
+		return val, exist, err
+	}
+	val, err = tc.codec.Decode(raw)
+	return val, true, err
+}
+
+func (tc *TypedCache[K, V]) Set(key K, val V, expiriesSecond int) error {
+	return tc.SetCtx(context.Background(), key, val, expiriesSecond)
+}
+
+func (tc *TypedCache[K, V]) SetCtx(ctx context.Context, key K, val V, expiriesSecond int) error {
+	raw, err := tc.codec.Encode(val)
+	if err != nil {
+		return err
+	}
+	return tc.ch.SetCtx(ctx, tc.encKey(key), raw, expiriesSecond)
+}
+
+// OnSet повертає значення з кешу, а якщо його немає — викликає fn і зберігає результат.
+func (tc *TypedCache[K, V]) OnSet(key K, fn func() (V, error), expiriesSecond int) (V, error) {
+	return tc.OnSetCtx(context.Background(), key, func(context.Context) (V, error) {
+		return fn()
+	}, expiriesSecond)
+}
+
+// OnSetCtx — див. Cache.OnSetCtx. Результат завжди декодується з raw: у режимі
+// WithStaleWhileRevalidate fn може виконуватися у фоні вже після повернення OnSetCtx.
+func (tc *TypedCache[K, V]) OnSetCtx(ctx context.Context, key K, fn func(ctx context.Context) (V, error), expiriesSecond int) (val V, err error) {
+	raw, err := tc.ch.OnSetCtx(ctx, tc.encKey(key), func(ctx context.Context) ([]byte, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return tc.codec.Encode(v)
+	}, expiriesSecond)
+	if err != nil {
+		return val, err
+	}
+	return tc.codec.Decode(raw)
+}
+
+func (tc *TypedCache[K, V]) Del(key K) error {
+	return tc.DelCtx(context.Background(), key)
+}
+
+func (tc *TypedCache[K, V]) DelCtx(ctx context.Context, key K) error {
+	return tc.ch.DelCtx(ctx, tc.encKey(key))
+}
@@ -0,0 +1,83 @@
+package cache_test
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/drivers"
+)
+
+func TestTypedCache(t *testing.T) {
+	c := cache.NewCache(drivers.NewFreeCacheDriver(freecache.NewCache(10 * 1024 * 1024)))
+	tc := cache.NewTypedCache[int, testObj](c, func(k int) []byte {
+		return []byte("obj:" + strconv.Itoa(k))
+	}, nil)
+
+	want := testObj{A: 1, B: "one"}
+	if err := tc.Set(1, want, 60); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	got, exist, err := tc.Get(1)
+	if err != nil || !exist || got != want {
+		t.Fatalf("Get(): want=%+v got=%+v exist=%v err=%v", want, got, exist, err)
+	}
+
+	// ключ кодується через KeyEncoder, тож сирий Cache бачить те саме значення
+	if _, exist, _ := c.Get([]byte("obj:1")); !exist {
+		t.Fatalf("raw Get(): expected key encoded by KeyEncoder")
+	}
+
+	calls := 0
+	load := func() (testObj, error) {
+		calls++
+		return testObj{A: 2, B: "two"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		got, err = tc.OnSet(2, load, 60)
+		if err != nil {
+			t.Fatalf("OnSet(): %v", err)
+		}
+		if got != (testObj{A: 2, B: "two"}) {
+			t.Fatalf("OnSet(): unexpected got=%+v", got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("OnSet(): expected calls=1, got %d", calls)
+	}
+
+	got, exist, err = tc.GetAndDel(2)
+	if err != nil || !exist || got.A != 2 {
+		t.Fatalf("GetAndDel(): got=%+v exist=%v err=%v", got, exist, err)
+	}
+	if _, exist, _ = tc.Get(2); exist {
+		t.Fatalf("Get(): expected key removed by GetAndDel")
+	}
+}
+
+func TestTypedCacheOnSetStale(t *testing.T) {
+	var now atomic.Int64
+	now.Store(time.Now().UnixNano())
+	c := newFreeCache(cache.WithStaleWhileRevalidate(60), cache.WithClock(func() time.Time {
+		return time.Unix(0, now.Load())
+	}))
+	tc := cache.NewTypedCache[string, testObj](c, cache.StringKey, nil)
+
+	if _, err := tc.OnSet("k", func() (testObj, error) { return testObj{A: 1}, nil }, 1); err != nil {
+		t.Fatalf("OnSet(): %v", err)
+	}
+	now.Add(int64(2 * time.Second))
+
+	// фонове оновлення не ділить стан з викликачем: під -race тут не має бути гонки
+	got, err := tc.OnSet("k", func() (testObj, error) { return testObj{A: 2}, nil }, 1)
+	if err != nil || got.A != 1 {
+		t.Fatalf("OnSet() stale: got=%+v err=%v", got, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close(): %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// ChunkUpdateError повертає UpdateChunk, коли припиняє спроби:
+// вичерпано MaxAttempts через ErrChunkConflict або скасовано ctx під час очікування.
+type ChunkUpdateError struct {
+	Name     string
+	Attempts int
+	Err      error
+}
+
+func (e *ChunkUpdateError) Error() string {
+	return fmt.Sprintf("оновлення чанку %q не вдалося після %d спроб: %v", e.Name, e.Attempts, e.Err)
+}
+
+func (e *ChunkUpdateError) Unwrap() error {
+	return e.Err
+}
+
+// UpdateOption налаштовує UpdateChunk.
+type UpdateOption func(*updateOptions)
+
+type updateOptions struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	chunkOpts   []ChunkOption
+}
+
+// WithMaxAttempts задає максимальну кількість спроб (за замовчуванням 10).
+func WithMaxAttempts(n int) UpdateOption {
+	return func(o *updateOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff задає експоненційну затримку між спробами: base, 2*base, 4*base, ... але не більше max.
+// Фактична затримка випадкова в [0, поточна) (full jitter). За замовчуванням 5ms і 500ms.
+func WithBackoff(base, max time.Duration) UpdateOption {
+	return func(o *updateOptions) {
+		o.baseDelay = base
+		o.maxDelay = max
+	}
+}
+
+// WithChunkOptions передає опції в Cache.Chunk при кожному перезавантаженні чанку.
+func WithChunkOptions(opts ...ChunkOption) UpdateOption {
+	return func(o *updateOptions) {
+		o.chunkOpts = append(o.chunkOpts, opts...)
+	}
+}
+
+// UpdateFn застосовує зміни до свіжо завантаженого чанку.
+// Може викликатися кілька разів, тож не повинна мати побічних ефектів поза чанком.
+type UpdateFn func(ch *Chunk) error
+
+// UpdateChunk виконує цикл reload -> fn -> SaveChanges і повторює його на ErrChunkConflict.
+func (ch *Cache) UpdateChunk(name string, expiriesSecond int, fn UpdateFn, opts ...UpdateOption) error {
+	return ch.UpdateChunkCtx(context.Background(), name, expiriesSecond, fn, opts...)
+}
+
+// UpdateChunkCtx — варіант UpdateChunk з context.Context.
+//
+// Помилки fn та драйвера (крім ErrChunkConflict) повертаються одразу, без повторів.
+// Якщо спроби вичерпано або ctx скасовано під час очікування, повертається *ChunkUpdateError.
+func (ch *Cache) UpdateChunkCtx(ctx context.Context, name string, expiriesSecond int, fn UpdateFn, opts ...UpdateOption) error {
+	o := updateOptions{
+		maxAttempts: 10,
+		baseDelay:   5 * time.Millisecond,
+		maxDelay:    500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var (
+		attempt int
+		err     error
+	)
+	for attempt = 1; ; attempt++ {
+		err = ch.updateChunkOnce(ctx, name, expiriesSecond, fn, o.chunkOpts)
+		if !errors.Is(err, ErrChunkConflict) {
+			return err
+		}
+		if attempt >= o.maxAttempts {
+			break
+		}
+
+		timer := time.NewTimer(backoffDelay(attempt, o.baseDelay, o.maxDelay))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return &ChunkUpdateError{Name: name, Attempts: attempt, Err: ctx.Err()}
+		}
+	}
+	return &ChunkUpdateError{Name: name, Attempts: attempt, Err: err}
+}
+
+func (ch *Cache) updateChunkOnce(ctx context.Context, name string, expiriesSecond int, fn UpdateFn, opts []ChunkOption) error {
+	chunk, err := ch.ChunkCtx(ctx, name, expiriesSecond, opts...)
+	if err != nil {
+		return err
+	}
+	if err := fn(chunk); err != nil {
+		return err
+	}
+	return chunk.SaveChangesCtx(ctx)
+}
+
+// backoffDelay повертає випадкову затримку в [0, min(max, base*2^(attempt-1))).
+func backoffDelay(attempt int, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return rand.N(d)
+}
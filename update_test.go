@@ -0,0 +1,117 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+func TestUpdateChunk(t *testing.T) {
+	const (
+		chunkName = "chunk_update"
+		workers   = 8
+		perWork   = 25
+	)
+	c := newFreeCache()
+	key := []byte("counter")
+
+	runConcurrently(workers, func(int) {
+		for i := 0; i < perWork; i++ {
+			err := c.UpdateChunk(chunkName, 60, func(ch *cache.Chunk) error {
+				var n int
+				if _, err := ch.Get(key, &n); err != nil {
+					return err
+				}
+				return ch.Set(key, n+1)
+			}, cache.WithMaxAttempts(1000), cache.WithBackoff(time.Millisecond, 10*time.Millisecond))
+			if err != nil {
+				t.Errorf("UpdateChunk(): %v", err)
+				return
+			}
+		}
+	})
+
+	ch, err := c.Chunk(chunkName, 60)
+	if err != nil {
+		t.Fatalf("Chunk(): %v", err)
+	}
+	var n int
+	if _, err := ch.Get(key, &n); err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if n != workers*perWork {
+		t.Fatalf("expected counter=%d, got %d", workers*perWork, n)
+	}
+}
+
+// conflictingUpdate повертає UpdateFn, яка під час кожної спроби комітить паралельну зміну того ж чанку,
+// тож SaveChanges завжди отримує ErrChunkConflict.
+func conflictingUpdate(t *testing.T, c *cache.Cache, name string, calls *int) cache.UpdateFn {
+	return func(ch *cache.Chunk) error {
+		*calls++
+		other, err := c.Chunk(name, 60)
+		if err != nil {
+			t.Fatalf("Chunk(): %v", err)
+		}
+		other.SetRaw([]byte("other"), []byte{byte(*calls)})
+		if err := other.SaveChanges(); err != nil {
+			t.Fatalf("SaveChanges(): %v", err)
+		}
+		ch.SetRaw([]byte("mine"), []byte("x"))
+		return nil
+	}
+}
+
+func TestUpdateChunkGivesUp(t *testing.T) {
+	const chunkName = "chunk_update_give_up"
+	c := newFreeCache()
+
+	calls := 0
+	err := c.UpdateChunk(chunkName, 60, conflictingUpdate(t, c, chunkName, &calls),
+		cache.WithMaxAttempts(3), cache.WithBackoff(time.Millisecond, time.Millisecond))
+
+	var uerr *cache.ChunkUpdateError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("expected *ChunkUpdateError, got %v", err)
+	}
+	if uerr.Attempts != 3 || calls != 3 {
+		t.Fatalf("expected 3 attempts, got Attempts=%d calls=%d", uerr.Attempts, calls)
+	}
+	if !errors.Is(err, cache.ErrChunkConflict) {
+		t.Fatalf("expected wrapped ErrChunkConflict, got %v", err)
+	}
+
+	// помилка fn повертається одразу, без повторів
+	errFn := errors.New("fn failed")
+	calls = 0
+	err = c.UpdateChunk(chunkName, 60, func(*cache.Chunk) error {
+		calls++
+		return errFn
+	})
+	if !errors.Is(err, errFn) || calls != 1 {
+		t.Fatalf("expected fn error without retries, got err=%v calls=%d", err, calls)
+	}
+}
+
+func TestUpdateChunkContextCanceled(t *testing.T) {
+	const chunkName = "chunk_update_ctx"
+	c := newFreeCache()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := c.UpdateChunkCtx(ctx, chunkName, 60, conflictingUpdate(t, c, chunkName, &calls),
+		cache.WithMaxAttempts(1000), cache.WithBackoff(time.Second, time.Second))
+
+	var uerr *cache.ChunkUpdateError
+	if !errors.As(err, &uerr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected *ChunkUpdateError wrapping DeadlineExceeded, got %v", err)
+	}
+	if uerr.Attempts != calls {
+		t.Fatalf("expected Attempts=%d, got %d", calls, uerr.Attempts)
+	}
+}
@@ -0,0 +1,232 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+	"github.com/v-grabko1999/cache/drivers"
+)
+
+// blockingDriver затримує кожен Set, доки не закрито release; started закривається на першому Set.
+type blockingDriver struct {
+	cache.CacheDriver
+
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDriver) Set(key, val []byte, expiriesSecond int) error {
+	d.once.Do(func() { close(d.started) })
+	<-d.release
+	return d.CacheDriver.Set(key, val, expiriesSecond)
+}
+
+// failingBatchDriver повертає errInjected з першого SetMany; failed закривається після нього.
+type failingBatchDriver struct {
+	*drivers.BadgerDBDriver
+
+	done   atomic.Bool
+	failed chan struct{}
+}
+
+var errInjected = errors.New("injected")
+
+func (d *failingBatchDriver) SetMany(ctx context.Context, items map[string][]byte, expiriesSecond int) error {
+	if d.done.CompareAndSwap(false, true) {
+		defer close(d.failed)
+		return errInjected
+	}
+	return d.BadgerDBDriver.SetMany(ctx, items, expiriesSecond)
+}
+
+func TestWriteBehindDriver(t *testing.T) {
+	dr, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	c := cache.NewCache(drivers.NewWriteBehindDriver(dr, drivers.WithFlushInterval(10*time.Millisecond)))
+	defer c.Close()
+
+	testLogic(t, c)
+	testLogicChunk(t, c)
+}
+
+func TestWriteBehindDriverBuffer(t *testing.T) {
+	dir := t.TempDir()
+	inner, err := drivers.NewBadgerDBDriver(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	dr := drivers.NewWriteBehindDriver(inner, drivers.WithFlushInterval(time.Hour))
+
+	for i := 0; i < 10; i++ {
+		if err := dr.Set([]byte(fmt.Sprint("k", i)), []byte("v"), 0); err != nil {
+			t.Fatalf("Set(): %v", err)
+		}
+	}
+	if err := dr.Del([]byte("k0")); err != nil {
+		t.Fatalf("Del(): %v", err)
+	}
+
+	// буфер ще не записано, але читання його бачать
+	if _, exist, _ := inner.Get([]byte("k1")); exist {
+		t.Fatal("expected k1 to be buffered, found in inner driver")
+	}
+	if _, exist, _ := dr.Get([]byte("k1")); !exist {
+		t.Fatal("Get(k1): pending write is not visible")
+	}
+	if _, exist, _ := dr.Get([]byte("k0")); exist {
+		t.Fatal("Get(k0): pending delete is not visible")
+	}
+
+	if err := dr.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush(): %v", err)
+	}
+	if _, exist, _ := inner.Get([]byte("k1")); !exist {
+		t.Fatal("Flush(): k1 was not written")
+	}
+
+	// Close записує залишок буфера перед закриттям inner
+	if err := dr.Set([]byte("last"), []byte("v"), 0); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if err := dr.Close(); err != nil {
+		t.Fatalf("Close(): %v", err)
+	}
+	if err := dr.Set([]byte("late"), []byte("v"), 0); !errors.Is(err, drivers.ErrDriverClosed) {
+		t.Fatalf("Set() after Close: expected ErrDriverClosed, got %v", err)
+	}
+
+	reopened, err := drivers.NewBadgerDBDriver(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	defer reopened.Close()
+	for key, want := range map[string]bool{"k0": false, "k9": true, "last": true} {
+		if _, exist, _ := reopened.Get([]byte(key)); exist != want {
+			t.Fatalf("after Close: %s exist=%v, want %v", key, exist, want)
+		}
+	}
+}
+
+func TestWriteBehindDriverBackPressure(t *testing.T) {
+	inner := &blockingDriver{
+		CacheDriver: newFreeCacheDriver(),
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	dr := drivers.NewWriteBehindDriver(inner,
+		drivers.WithFlushInterval(time.Hour), drivers.WithFlushSize(2), drivers.WithMaxPending(2))
+
+	// перші два ключі запускають скидання, яке зависає в inner, наступні два заповнюють буфер
+	for i := 0; i < 4; i++ {
+		if err := dr.Set([]byte(fmt.Sprint("k", i)), []byte("v"), 0); err != nil {
+			t.Fatalf("Set(): %v", err)
+		}
+		if i == 1 {
+			<-inner.started
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := dr.SetCtx(ctx, []byte("k4"), []byte("v"), 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SetCtx() with full buffer: expected DeadlineExceeded, got %v", err)
+	}
+
+	close(inner.release)
+	if err := dr.Set([]byte("k4"), []byte("v"), 0); err != nil {
+		t.Fatalf("Set() after flush: %v", err)
+	}
+	if err := dr.Close(); err != nil {
+		t.Fatalf("Close(): %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, exist, _ := inner.Get([]byte(fmt.Sprint("k", i))); !exist {
+			t.Fatalf("k%d was not written", i)
+		}
+	}
+}
+
+func TestWriteBehindDriverCancelledFlush(t *testing.T) {
+	inner, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	dr := drivers.NewWriteBehindDriver(inner, drivers.WithFlushInterval(time.Hour))
+	defer dr.Close()
+
+	if err := dr.Set([]byte("k"), []byte("v"), 0); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := dr.Flush(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Flush() with cancelled ctx: expected context.Canceled, got %v", err)
+	}
+	if err := dr.Scan(ctx, nil, func(_, _ []byte) bool { return true }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Scan() with cancelled ctx: expected context.Canceled, got %v", err)
+	}
+
+	// скасований Flush не втрачає буфер
+	if _, exist, _ := dr.Get([]byte("k")); !exist {
+		t.Fatal("Get(): buffered write lost after cancelled Flush")
+	}
+	if err := dr.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush(): %v", err)
+	}
+	if _, exist, _ := inner.Get([]byte("k")); !exist {
+		t.Fatal("Flush(): k was not written")
+	}
+}
+
+// Помилку фонового скидання повертає Flush, навіть якщо між ними були Scan і DelPrefix.
+func TestWriteBehindDriverFlushError(t *testing.T) {
+	badger, err := drivers.NewBadgerDBDriver(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerDBDriver(): %v", err)
+	}
+	inner := &failingBatchDriver{BadgerDBDriver: badger, failed: make(chan struct{})}
+	dr := drivers.NewWriteBehindDriver(inner, drivers.WithFlushInterval(time.Hour), drivers.WithFlushSize(1))
+	defer dr.Close()
+
+	if err := dr.Set([]byte("lost"), []byte("v"), 0); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	<-inner.failed
+	time.Sleep(50 * time.Millisecond) // фоновий цикл зберігає помилку після повернення SetMany
+
+	ctx := context.Background()
+	if err := dr.Scan(ctx, nil, func(_, _ []byte) bool { return true }); err != nil {
+		t.Fatalf("Scan(): %v", err)
+	}
+	if err := dr.DelPrefix(ctx, []byte("x")); err != nil {
+		t.Fatalf("DelPrefix(): %v", err)
+	}
+	if err := dr.Flush(ctx); !errors.Is(err, errInjected) {
+		t.Fatalf("Flush(): expected background flush error, got %v", err)
+	}
+	if err := dr.Flush(ctx); err != nil {
+		t.Fatalf("Flush(): error reported twice: %v", err)
+	}
+}
+
+// Непозитивний WithFlushInterval замінюється типовим, а не роняє фонове скидання.
+func TestWriteBehindDriverZeroInterval(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		dr := drivers.NewWriteBehindDriver(newFreeCacheDriver(), drivers.WithFlushInterval(d))
+		if err := dr.Set([]byte("k"), []byte("v"), 0); err != nil {
+			t.Fatalf("Set(): %v", err)
+		}
+		if err := dr.Close(); err != nil {
+			t.Fatalf("Close(): %v", err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package cache
+
+import "math"
+
+// shouldRecomputeEarly реалізує XFetch (Vattani et al., "Optimal Probabilistic Cache Stampede Prevention"):
+// повертає true, якщо now - delta*beta*ln(rand) >= expiry.
+// Записи без метаданих XFetch ніколи не переобчислюються достроково.
+func (ch *Cache) shouldRecomputeEarly(env *envelope) bool {
+	if ch.xfetchBeta <= 0 || env.Expire == 0 || env.Delta <= 0 {
+		return false
+	}
+
+	r := ch.rand()
+	if r <= 0 {
+		return true
+	}
+	if r > 1 {
+		r = 1
+	}
+
+	// ln(r) <= 0, тож gap — невідʼємний "зсув у майбутнє"
+	gap := -float64(env.Delta) * ch.xfetchBeta * math.Log(r)
+	return float64(ch.now().UnixNano())+gap >= float64(env.Expire)
+}
@@ -0,0 +1,79 @@
+package cache_test
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/v-grabko1999/cache"
+)
+
+// fakeClock — керований годинник для WithClock.
+type fakeClock struct {
+	ns atomic.Int64
+}
+
+func (c *fakeClock) Now() time.Time          { return time.Unix(0, c.ns.Load()) }
+func (c *fakeClock) Advance(d time.Duration) { c.ns.Add(int64(d)) }
+
+func TestOnSetXFetch(t *testing.T) {
+	clock := &fakeClock{}
+	clock.ns.Store(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+
+	var rnd atomic.Uint64
+	setRand := func(v float64) { rnd.Store(math.Float64bits(v)) }
+	setRand(1)
+
+	c := newFreeCache(
+		cache.WithXFetch(1),
+		cache.WithClock(clock.Now),
+		cache.WithRand(func() float64 { return math.Float64frombits(rnd.Load()) }),
+	)
+	key := []byte("xfetch key")
+
+	calls := 0
+	load := func() ([]byte, error) {
+		calls++
+		clock.Advance(10 * time.Second) // delta = 10s
+		return Value, nil
+	}
+
+	// expiry = час завершення завантаження + 100s
+	if _, err := c.OnSet(key, load, 100); err != nil {
+		t.Fatalf("OnSet(): %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected calls=1, got %d", calls)
+	}
+
+	// rand=1 => ln(1)=0: до expiry дострокового переобчислення немає
+	clock.Advance(95 * time.Second)
+	if _, err := c.OnSet(key, load, 100); err != nil {
+		t.Fatalf("OnSet(): %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("rand=1: expected no early recompute, got calls=%d", calls)
+	}
+
+	// rand=1/e => gap = delta*beta = 10s: за 5s до expiry переобчислюємо
+	setRand(1 / math.E)
+	if _, err := c.OnSet(key, load, 100); err != nil {
+		t.Fatalf("OnSet(): %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("rand=1/e: expected early recompute, got calls=%d", calls)
+	}
+	if got := c.Stats().EarlyRecomputes; got != 1 {
+		t.Fatalf("expected EarlyRecomputes=1, got %d", got)
+	}
+
+	// нове значення має новий expiry: за 20s до нього gap=10s недостатньо
+	clock.Advance(80 * time.Second)
+	if _, err := c.OnSet(key, load, 100); err != nil {
+		t.Fatalf("OnSet(): %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected no recompute 20s before expiry, got calls=%d", calls)
+	}
+}